@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-mr/master"
+	"go-mr/masterapi"
+	"go-mr/storage"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// Command line flags
+	var (
+		inputFile    = flag.String("input", "", "Comma separated input files, directories or glob patterns of a job submitted at startup, read through the storage backend (optional)")
+		pluginFile   = flag.String("plugin", "", "Plugin file path for map/reduce functions of the -input job")
+		storageRoot  = flag.String("storage-root", storage.StorageRoot(), "Root directory for splits, intermediate files and outputs (env "+storage.StorageRootEnv+")")
+		storageSpec  = flag.String("storage", storage.BackendSpec(), "Storage backend: local or s3://bucket?endpoint=URL (env "+storage.StorageBackendEnv+")")
+		outputDir    = flag.String("output", "", "Output directory of the -input job (default <storage-root>/output/<job ID>)")
+		port         = flag.String("port", "8080", "Master server port")
+		nReducers    = flag.Int("reducers", master.DefaultNumberReducers, "Number of reduce tasks")
+		chunkSize    = flag.Int("chunk-size", 1024*1024, "Chunk size in bytes for splitting")
+		virtual      = flag.Bool("virtual-splits", false, "Give map tasks byte ranges of the inputs instead of copying them into chunk files")
+		metadataPath = flag.String("metadata", "", "Metadata file path (default <storage-root>/metadata.json)")
+		statePath    = flag.String("state", "", "File the scheduler state is saved to and resumed from (default <storage-root>/master-state.json)")
+		totalOrder   = flag.Bool("total-order", false, "Range partition sampled keys so the concatenated outputs are globally sorted")
+		sampleSize   = flag.Int("sample-size", master.DefaultSampleSize, "Keys sampled to pick reducer ranges for -total-order")
+		inputFormat  = flag.String("format", storage.DefaultInputFormat, "Record format of the -input job: "+strings.Join(storage.InputFormatNames(), ", "))
+		maxAttempts  = flag.Int("max-attempts", master.DefaultMaxTaskAttempts, "Maximum attempts per task before the job fails")
+		taskTimeout  = flag.Duration("task-timeout", master.DefaultTaskTimeout, "Time a worker may hold a task before it is re-assigned")
+		heartbeat    = flag.Duration("heartbeat-interval", master.DefaultHeartbeatInterval, "Time between worker health checks")
+		maxMissed    = flag.Int("max-missed-heartbeats", master.DefaultMaxMissedHeartbeats, "Missed health checks before a worker is considered dead")
+	)
+	flag.Parse()
+
+	// An initial job is optional, more jobs arrive through SubmitJob
+	if *inputFile != "" {
+		if *pluginFile == "" {
+			log.Fatal("Plugin file is required with -input. Use -plugin flag")
+		}
+		if _, err := os.Stat(*pluginFile); os.IsNotExist(err) {
+			log.Fatalf("Plugin file does not exist: %s", *pluginFile)
+		}
+	}
+
+	if *metadataPath == "" {
+		*metadataPath = filepath.Join(*storageRoot, "metadata.json")
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(*storageRoot, "master-state.json")
+	}
+
+	fmt.Printf("Starting MapReduce Master Node\n")
+	fmt.Printf("Storage root: %s\n", *storageRoot)
+	fmt.Printf("Server port: %s\n", *port)
+
+	backend, err := storage.NewBackend(*storageSpec)
+	if err != nil {
+		log.Fatalf("Failed to create storage backend: %v", err)
+	}
+
+	// Initialize file splitter
+	splitter, err := storage.NewSplitter(*chunkSize, *storageRoot, *metadataPath)
+	if err != nil {
+		log.Fatalf("Failed to create splitter: %v", err)
+	}
+	splitter.Storage = backend
+	splitter.Virtual = *virtual
+
+	// Create master node
+	masterNode := master.NewMasterNode(splitter)
+	masterNode.SetMaxTaskAttempts(*maxAttempts)
+	masterNode.SetTaskTimeout(*taskTimeout)
+	masterNode.SetHeartbeat(*heartbeat, *maxMissed)
+	masterNode.SetStatePath(*statePath)
+
+	// Resume the jobs of a previous run
+	resumed, err := masterNode.LoadState()
+	if err != nil {
+		log.Fatalf("Failed to load master state: %v", err)
+	}
+	if resumed > 0 {
+		fmt.Printf("Resumed %d unfinished jobs from %s\n", resumed, *statePath)
+	}
+
+	// Start the master scheduler
+	masterNode.StartScheduler()
+	fmt.Printf("Master scheduler started\n")
+
+	// The -input job of the previous run may be among the resumed jobs
+	if *inputFile != "" {
+		inputs := strings.Split(*inputFile, ",")
+		if jobID, ok := resumedJob(masterNode, backend, inputs); ok {
+			fmt.Printf("Not submitting %s again, resumed job %s is still processing it\n", *inputFile, jobID)
+		} else {
+			fmt.Printf("Submitting job for %s with plugin %s and %d reducers\n", *inputFile, *pluginFile, *nReducers)
+			jobID, err := masterNode.SubmitJob(master.JobSpec{
+				Inputs:         inputs,
+				PluginFile:     *pluginFile,
+				NumberReducers: *nReducers,
+				OutputDir:      *outputDir,
+				TotalOrder:     *totalOrder,
+				SampleSize:     *sampleSize,
+				InputFormat:    *inputFormat,
+			})
+			if err != nil {
+				log.Fatalf("Failed to submit job: %v", err)
+			}
+			fmt.Printf("Submitted job %s\n", jobID)
+		}
+	}
+
+	// Create gRPC server
+	lis, err := net.Listen("tcp", ":"+*port)
+	if err != nil {
+		log.Fatalf("Failed to listen on port %s: %v", *port, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	masterApiServer := master.NewMasterApiServer(masterNode)
+	masterapi.RegisterMasterApiServer(grpcServer, masterApiServer)
+
+	// Start gRPC server in a goroutine
+	go func() {
+		fmt.Printf("Master gRPC server listening on port %s\n", *port)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("Failed to serve gRPC server: %v", err)
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	// Print status and wait
+	fmt.Printf("Master node is running. Press Ctrl+C to stop.\n")
+	fmt.Printf("Workers can connect to: localhost:%s\n", *port)
+
+	// Block until we receive a signal
+	<-sigChan
+	fmt.Printf("\nShutting down master node...\n")
+
+	// Graceful shutdown
+	grpcServer.GracefulStop()
+	fmt.Printf("Master node stopped.\n")
+}
+
+// resumedJob returns the ID of a resumed job that hasn't finished and reads
+// the same files as inputs, which a master restarted with the same -input
+// flag would otherwise run twice.
+func resumedJob(masterNode *master.MasterNode, backend storage.Backend, inputs []string) (string, bool) {
+	files, err := storage.ExpandInputs(backend, inputs)
+	if err != nil {
+		return "", false
+	}
+	for _, status := range masterNode.ListJobs() {
+		if status.Phase != master.PhaseMap && status.Phase != master.PhaseReduce {
+			continue
+		}
+		if slices.Equal(status.Inputs, files) {
+			return status.JobID, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-mr/masterapi"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const usage = `Usage: mrctl [flags] <command> [arguments]
+
+Commands:
+  list                       List every job known to the master
+  status <job-id>            Show the phase, task counts and assignments of a job
+  cancel <job-id>            Cancel a job
+  submit [submit flags] <input>...
+                             Submit a job and print its ID. Inputs are files,
+                             directories or quoted glob patterns on the master;
+                             the job is in the splitting phase until they are split
+
+Flags:
+`
+
+func main() {
+	// Command line flags
+	var (
+		masterAddress = flag.String("master", "localhost:8080", "Master node address as host:port")
+		jsonOutput    = flag.Bool("json", false, "Print results as JSON instead of a table")
+		timeout       = flag.Duration("timeout", 10*time.Second, "Timeout of the request to the master")
+	)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conn, err := grpc.NewClient(*masterAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to master: %v", err)
+	}
+	defer conn.Close()
+	client := masterapi.NewMasterApiClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	args := flag.Args()[1:]
+	switch command := flag.Arg(0); command {
+	case "list":
+		resp, err := client.ListJobs(ctx, &masterapi.ListJobsRequest{})
+		if err != nil {
+			log.Fatalf("Failed to list jobs: %v", err)
+		}
+		if *jsonOutput {
+			printJSON(resp)
+			return
+		}
+		printJobTable(os.Stdout, resp.GetJobs())
+
+	case "status":
+		jobID := jobArgument(command, args)
+		resp, err := client.GetJobStatus(ctx, &masterapi.JobStatusRequest{Jobid: jobID})
+		if err != nil {
+			log.Fatalf("Failed to get status of job %s: %v", jobID, err)
+		}
+		if *jsonOutput {
+			printJSON(resp)
+			return
+		}
+		printJobStatus(os.Stdout, resp)
+
+	case "cancel":
+		jobID := jobArgument(command, args)
+		resp, err := client.CancelJob(ctx, &masterapi.CancelJobRequest{Jobid: jobID})
+		if err != nil {
+			log.Fatalf("Failed to cancel job %s: %v", jobID, err)
+		}
+		if *jsonOutput {
+			printJSON(resp)
+			return
+		}
+		fmt.Printf("Job %s is %s\n", jobID, resp.GetPhase())
+
+	case "submit":
+		req := parseSubmit(args)
+		resp, err := client.SubmitJob(ctx, req)
+		if err != nil {
+			log.Fatalf("Failed to submit job: %v", err)
+		}
+		if *jsonOutput {
+			printJSON(resp)
+			return
+		}
+		fmt.Println(resp.GetJobid())
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// jobArgument returns the single job ID argument of a command.
+func jobArgument(command string, args []string) string {
+	if len(args) != 1 {
+		log.Fatalf("Usage: mrctl %s <job-id>", command)
+	}
+	return args[0]
+}
+
+// parseSubmit parses the flags and inputs of the submit command.
+func parseSubmit(args []string) *masterapi.SubmitJobRequest {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	var (
+		pluginFile = fs.String("plugin", "", "Plugin file path for map/reduce functions, as seen by the master and workers")
+		nReducers  = fs.Int("reducers", 0, "Number of reduce tasks (default chosen by the master)")
+		outputDir  = fs.String("output", "", "Output directory (default <storage-root>/output/<job ID>)")
+		totalOrder = fs.Bool("total-order", false, "Range partition sampled keys so the concatenated outputs are globally sorted")
+		sampleSize = fs.Int("sample-size", 0, "Keys sampled to pick reducer ranges for -total-order (default chosen by the master)")
+		format     = fs.String("format", "", "Record format of the inputs: lines, csv, jsonl or binary (default lines)")
+	)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		log.Fatal("At least one input is required: mrctl submit -plugin <file> <input>...")
+	}
+	if *pluginFile == "" {
+		log.Fatal("Plugin file is required. Use -plugin flag")
+	}
+
+	return &masterapi.SubmitJobRequest{
+		Inputs:     fs.Args(),
+		Plugin:     *pluginFile,
+		Reducers:   int32(*nReducers),
+		Outputdir:  *outputDir,
+		Totalorder: *totalOrder,
+		Samplesize: int32(*sampleSize),
+		Format:     *format,
+	}
+}
+
+func printJSON(msg proto.Message) {
+	data, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		log.Fatalf("Failed to encode response: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+// printJobTable prints one line per job with its task counts as
+// done/total.
+func printJobTable(out io.Writer, jobs []*masterapi.JobStatus) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tPHASE\tMAP\tREDUCE\tFAILED\tOUTPUT")
+	for _, job := range jobs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			job.GetJobid(),
+			job.GetPhase(),
+			progress(job.GetMaptasks()),
+			progress(job.GetReducetasks()),
+			job.GetMaptasks().GetFailed()+job.GetReducetasks().GetFailed(),
+			job.GetOutputdir(),
+		)
+	}
+	tw.Flush()
+}
+
+// printJobStatus prints the details of one job.
+func printJobStatus(out io.Writer, job *masterapi.JobStatus) {
+	fmt.Fprintf(out, "Job:    %s\n", job.GetJobid())
+	fmt.Fprintf(out, "Phase:  %s\n", job.GetPhase())
+	fmt.Fprintf(out, "Inputs: %s\n", strings.Join(job.GetInputs(), ", "))
+	fmt.Fprintf(out, "Output: %s\n", job.GetOutputdir())
+	if job.GetFailurereason() != "" {
+		fmt.Fprintf(out, "Error:  %s\n", job.GetFailurereason())
+	}
+	fmt.Fprintln(out)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASKS\tPENDING\tACTIVE\tDONE\tFAILED")
+	for _, row := range []struct {
+		name   string
+		counts *masterapi.TaskCounts
+	}{
+		{"map", job.GetMaptasks()},
+		{"reduce", job.GetReducetasks()},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", row.name,
+			row.counts.GetPending(), row.counts.GetActive(), row.counts.GetDone(), row.counts.GetFailed())
+	}
+	tw.Flush()
+
+	if len(job.GetAssignments()) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKER\tRUNNING")
+	for _, a := range job.GetAssignments() {
+		fmt.Fprintf(tw, "%s\t%s\n", a.GetWorkerid(), strings.Join(a.GetTaskids(), ", "))
+	}
+	tw.Flush()
+}
+
+// progress formats task counts as done/total.
+func progress(counts *masterapi.TaskCounts) string {
+	total := counts.GetPending() + counts.GetActive() + counts.GetDone()
+	return fmt.Sprintf("%d/%d", counts.GetDone(), total)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-mr/storage"
+	"go-mr/worker"
+	"go-mr/workerapi"
+	"log"
+	"net"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	// Command line flags
+	var (
+		address       = flag.String("address", "", "Address other nodes use to reach this worker (default the address the master sees it connect from)")
+		port          = flag.String("port", "9090", "Worker server port")
+		masterAddress = flag.String("master-address", "localhost", "Master node address")
+		masterPort    = flag.String("master-port", "8080", "Master node port")
+		mapBuffer     = flag.Int64("map-buffer", worker.DefaultMapBufferBytes, "Bytes of map output buffered in memory before spilling to disk")
+		storageSpec   = flag.String("storage", storage.BackendSpec(), "Storage backend: local or s3://bucket?endpoint=URL (env "+storage.StorageBackendEnv+")")
+	)
+	flag.Parse()
+
+	workerNode, err := worker.NewWorkerNode(*address, *port, *masterAddress, *masterPort)
+	if err != nil {
+		log.Fatalf("Failed to create worker node: %v", err)
+	}
+
+	workerNode.MapBufferBytes = *mapBuffer
+	workerNode.Storage, err = storage.NewBackend(*storageSpec)
+	if err != nil {
+		log.Fatalf("Failed to create storage backend: %v", err)
+	}
+
+	fmt.Printf("Starting MapReduce Worker Node %s\n", workerNode.ID)
+
+	// Create gRPC server
+	lis, err := net.Listen("tcp", ":"+*port)
+	if err != nil {
+		log.Fatalf("Failed to listen on port %s: %v", *port, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	workerapi.RegisterWorkerApiServer(grpcServer, worker.NewWorkerApiServer(workerNode))
+
+	// Start gRPC server in a goroutine
+	go func() {
+		fmt.Printf("Worker gRPC server listening on port %s\n", *port)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("Failed to serve gRPC server: %v", err)
+		}
+	}()
+
+	// Stop the task loop on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := workerNode.Run(ctx); err != nil {
+		log.Fatalf("Worker stopped: %v", err)
+	}
+
+	fmt.Printf("\nShutting down worker node...\n")
+	grpcServer.GracefulStop()
+	fmt.Printf("Worker node stopped.\n")
+}
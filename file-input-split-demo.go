@@ -7,13 +7,13 @@ import (
 func main() {
 
 	// Create a new Splitter instance with a chunk size of 128 KB
-	splitter, err := storage.NewSplitter(1024*128, "meta/metadata.json")
+	splitter, err := storage.NewSplitter(1024*128, storage.StorageRoot(), "meta/metadata.json")
 	if err != nil {
 		panic(err)
 	}
 
-	// Split the file "input.txt" and get the metadata
-	meta, err := splitter.Split("input.txt")
+	// Split the lines of the file "input.txt" and get the metadata
+	meta, err := splitter.Split("input.txt", storage.LineFormat{})
 	if err != nil {
 		panic(err)
 	}
@@ -3,6 +3,7 @@ module go-mr
 go 1.24.0
 
 require (
+	github.com/klauspost/compress v1.18.0
 	google.golang.org/grpc v1.72.2
 	google.golang.org/protobuf v1.36.6
 )
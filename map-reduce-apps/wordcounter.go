@@ -16,5 +16,20 @@ func Map(record string) []types.KeyValue {
 }
 
 func Reduce(key string, values []string) string {
-	return strconv.Itoa(len(values))
+	return sum(values)
+}
+
+// Combine adds up the counts of a word on the map side, so each word is
+// shuffled once per map task instead of once per occurrence.
+func Combine(key string, values []string) string {
+	return sum(values)
+}
+
+func sum(values []string) string {
+	total := 0
+	for _, v := range values {
+		n, _ := strconv.Atoi(v)
+		total += n
+	}
+	return strconv.Itoa(total)
 }
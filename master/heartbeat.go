@@ -0,0 +1,111 @@
+package master
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go-mr/workerapi"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// monitorWorkers probes every registered worker on each heartbeat interval
+// and hands workers that missed too many health checks to the scheduler.
+func (m *MasterNode) monitorWorkers() {
+	ticker := time.NewTicker(m.heartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		workers, clients := m.heartbeatTargets()
+
+		// Probe all workers at once so one unreachable worker doesn't
+		// delay the checks of the others. Probes run without the lock so
+		// registrations are never held up by a slow worker.
+		healthy := make([]bool, len(workers))
+		var wg sync.WaitGroup
+		for i, client := range clients {
+			if client == nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				healthy[i] = m.probeWorker(client)
+			}()
+		}
+		wg.Wait()
+
+		for _, workerID := range m.recordHeartbeats(workers, healthy) {
+			m.deadWorkerChannel <- workerID
+		}
+	}
+}
+
+// heartbeatTargets returns every registered worker with the client used to
+// probe it, connecting to workers that don't have one yet. A nil client
+// means the worker could not be dialled.
+func (m *MasterNode) heartbeatTargets() ([]*WorkerInfo, []workerapi.WorkerApiClient) {
+	m.workersMu.Lock()
+	defer m.workersMu.Unlock()
+
+	workers := make([]*WorkerInfo, 0, len(m.workers))
+	clients := make([]workerapi.WorkerApiClient, 0, len(m.workers))
+	for _, w := range m.workers {
+		if w.client == nil {
+			conn, err := grpc.NewClient(net.JoinHostPort(w.Address, w.Port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				fmt.Printf("Failed to connect to worker %s: %v\n", w.ID, err)
+			} else {
+				w.conn = conn
+				w.client = workerapi.NewWorkerApiClient(conn)
+			}
+		}
+		workers = append(workers, w)
+		clients = append(clients, w.client)
+	}
+	return workers, clients
+}
+
+// recordHeartbeats updates the health state of the probed workers and
+// returns the IDs of workers that just crossed the missed heartbeat limit.
+func (m *MasterNode) recordHeartbeats(workers []*WorkerInfo, healthy []bool) []string {
+	m.workersMu.Lock()
+	defer m.workersMu.Unlock()
+
+	var dead []string
+	for i, w := range workers {
+		if healthy[i] {
+			if !w.Active {
+				fmt.Printf("Worker %s is responding again\n", w.ID)
+			}
+			w.missedHeartbeats = 0
+			w.Active = true
+			continue
+		}
+		if !w.Active {
+			continue
+		}
+
+		w.missedHeartbeats++
+		if w.missedHeartbeats >= m.maxMissedHeartbeats {
+			fmt.Printf("[✗] Worker %s missed %d health checks, marking it dead\n", w.ID, w.missedHeartbeats)
+			w.Active = false
+			dead = append(dead, w.ID)
+		}
+	}
+	return dead
+}
+
+// probeWorker calls HealthCheck on a worker and reports whether it answered
+// healthy within one heartbeat interval.
+func (m *MasterNode) probeWorker(client workerapi.WorkerApiClient) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), m.heartbeatInterval)
+	defer cancel()
+
+	resp, err := client.HealthCheck(ctx, &workerapi.HealthCheckRequest{})
+	return err == nil && resp.GetHealthy()
+}
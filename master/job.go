@@ -0,0 +1,329 @@
+package master
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go-mr/storage"
+)
+
+// DefaultNumberReducers is the number of reduce tasks of a job that doesn't
+// ask for a specific count.
+const DefaultNumberReducers = 3
+
+// JobSpec describes a job submitted to the master.
+type JobSpec struct {
+	Inputs         []string // Input files, directories or glob patterns, each file split into map tasks
+	PluginFile     string   // Plugin with the Map and Reduce functions
+	NumberReducers int      // Number of reduce tasks, DefaultNumberReducers if zero
+	OutputDir      string   // Output directory, <storage-root>/output/<job ID> if empty
+	TotalOrder     bool     // Range partition sampled keys so outputs are globally sorted
+	SampleSize     int      // Keys sampled for TotalOrder, DefaultSampleSize if zero
+	InputFormat    string   // Record format of the inputs, storage.DefaultInputFormat if empty
+}
+
+// Job holds the task queues and phase of one submitted job. It is owned by
+// the scheduling loop once submitted.
+type Job struct {
+	ID                       string
+	inputs                   []string
+	numberReducers           int // Number of reducers to use
+	pluginfilepath           string
+	outputfilepath           string
+	phase                    ExecutionPhase
+	pendingTasks             []*TaskResponse
+	activeTasks              map[string]*taskLease        // taskID -> lease of the running attempt
+	reducerIntermediateFiles map[string][]string          // reducerID -> intermediate file paths
+	completedBy              map[string]string            // taskID -> worker that completed it
+	mapOutputs               map[string]map[string]string // map taskID -> reducerID -> file path
+	combinedRecords          map[string]int64             // map taskID -> records removed by the combiner
+	failedAttempts           map[string]int               // taskID -> attempts that failed or timed out
+	uncountedAttempts        map[string]int               // taskID -> attempts not counted against maxTaskAttempts
+	failureReason            string                       // Why the job entered PhaseFailed
+	splitDirs                []string                     // Split directories its map tasks read, referenced until it finishes
+	finalStatus              *JobStatus                   // Status once it finished, after its tasks were dropped
+}
+
+func newJob(id string, spec JobSpec) *Job {
+	return &Job{
+		ID:                       id,
+		inputs:                   spec.Inputs,
+		numberReducers:           spec.NumberReducers,
+		pluginfilepath:           spec.PluginFile,
+		outputfilepath:           spec.OutputDir,
+		phase:                    PhaseIdle,
+		pendingTasks:             make([]*TaskResponse, 0),
+		activeTasks:              make(map[string]*taskLease),
+		reducerIntermediateFiles: make(map[string][]string),
+		completedBy:              make(map[string]string),
+		mapOutputs:               make(map[string]map[string]string),
+		combinedRecords:          make(map[string]int64),
+		failedAttempts:           make(map[string]int),
+		uncountedAttempts:        make(map[string]int),
+	}
+}
+
+// SubmitJob validates a job, hands it to the scheduler and returns its ID.
+// The inputs are split and the map tasks created in the background, so a
+// large input doesn't keep the caller waiting; the job stays in the splitting
+// phase until then and fails if its inputs can't be split. Jobs run in the
+// order they were submitted; a worker only gets tasks of a later job once the
+// earlier ones have nothing pending. It must be called after StartScheduler.
+func (m *MasterNode) SubmitJob(spec JobSpec) (string, error) {
+	if len(spec.Inputs) == 0 {
+		return "", fmt.Errorf("job needs at least one input")
+	}
+	if spec.PluginFile == "" {
+		return "", fmt.Errorf("job needs a plugin file")
+	}
+	if _, err := os.Stat(spec.PluginFile); err != nil {
+		return "", fmt.Errorf("plugin file is not readable: %v", err)
+	}
+	if spec.NumberReducers < 0 {
+		return "", fmt.Errorf("invalid reducer count %d", spec.NumberReducers)
+	}
+	if spec.NumberReducers == 0 {
+		spec.NumberReducers = DefaultNumberReducers
+	}
+	if spec.SampleSize <= 0 {
+		spec.SampleSize = DefaultSampleSize
+	}
+	format, err := storage.LookupInputFormat(spec.InputFormat)
+	if err != nil {
+		return "", err
+	}
+
+	files, err := storage.ExpandInputs(m.storage, spec.Inputs)
+	if err != nil {
+		return "", err
+	}
+	spec.Inputs = files
+
+	id := fmt.Sprintf("job-%d", m.nextJobID.Add(1))
+	if spec.OutputDir == "" {
+		spec.OutputDir = filepath.Join(m.splitter.StorageRoot, "output", id)
+	}
+	job := newJob(id, spec)
+	job.phase = PhaseSplitting
+
+	m.jobSubmissionChannel <- job
+	go func() {
+		tasks, splitDirs, err := m.prepareMapTasks(id, spec, format)
+		m.splitDoneChannel <- &splitResult{JobID: id, Tasks: tasks, SplitDirs: splitDirs, Err: err}
+	}()
+	return id, nil
+}
+
+// splitResult carries the map tasks prepared for a job to the scheduling
+// loop, or the reason they couldn't be.
+type splitResult struct {
+	JobID     string
+	Tasks     []*TaskResponse
+	SplitDirs []string // Split directories the tasks read, acquired from the splitter
+	Err       error
+}
+
+// prepareMapTasks splits the inputs of a submitted job and creates its map
+// tasks. It runs outside the scheduling loop on a copy of the job the loop
+// never sees. It returns the split directories the tasks read, which the
+// job holds references on until it finishes.
+func (m *MasterNode) prepareMapTasks(id string, spec JobSpec, format storage.InputFormat) ([]*TaskResponse, []string, error) {
+	staged := newJob(id, spec)
+	splits, err := m.splitInputs(spec.Inputs, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs := splitDirs(splits)
+
+	err = m.loadMapTasks(staged, spec.Inputs, splits, format)
+	if err == nil && spec.TotalOrder {
+		if err = m.sampleKeyRanges(staged, spec.SampleSize, format); err != nil {
+			err = fmt.Errorf("failed to sample key ranges: %v", err)
+		}
+	}
+	if err != nil {
+		m.releaseSplits(dirs)
+		return nil, nil, err
+	}
+	return staged.pendingTasks, dirs, nil
+}
+
+// splitDirs returns the directories of the splits that have one. Virtual
+// splits read the input files themselves.
+func splitDirs(splits []*storage.InputFileMetadata) []string {
+	var dirs []string
+	for _, metadata := range splits {
+		if metadata.SplitDir != "" {
+			dirs = append(dirs, metadata.SplitDir)
+		}
+	}
+	return dirs
+}
+
+// startJob queues the map tasks of a job whose inputs were split. A job
+// cancelled while it was being split stays cancelled.
+func (m *MasterNode) startJob(result *splitResult) {
+	job, ok := m.jobs[result.JobID]
+	if !ok || job.phase != PhaseSplitting {
+		m.releaseSplits(result.SplitDirs)
+		return
+	}
+	m.stateChanged.Store(true)
+	if result.Err != nil {
+		m.failJob(job, result.Err.Error())
+		return
+	}
+
+	job.pendingTasks = result.Tasks
+	job.splitDirs = result.SplitDirs
+	for _, task := range job.pendingTasks {
+		m.tasks[task.TaskID] = task
+	}
+	job.phase = PhaseMap
+	fmt.Printf("Job %s has %d map tasks\n", job.ID, len(job.pendingTasks))
+}
+
+// splitInputs splits every input at record boundaries of format and returns
+// the split metadata. The splitter keeps its metadata in memory, so jobs
+// submitted at the same time are split one after another. Each split is
+// acquired for the job before the next job may split the same input again.
+func (m *MasterNode) splitInputs(inputs []string, format storage.InputFormat) ([]*storage.InputFileMetadata, error) {
+	m.splitMu.Lock()
+	defer m.splitMu.Unlock()
+
+	splits := make([]*storage.InputFileMetadata, 0, len(inputs))
+	for _, input := range inputs {
+		metadata, err := m.splitter.Split(input, format)
+		if err != nil {
+			m.releaseSplits(splitDirs(splits))
+			return nil, fmt.Errorf("failed to split %s: %v", input, err)
+		}
+		// Reference the split before another job can replace it
+		if metadata.SplitDir != "" {
+			m.splitter.Acquire(metadata.SplitDir)
+		}
+		if metadata.Compression != "" {
+			fmt.Printf("%s is %s compressed, read by a single map task\n", input, metadata.Compression)
+		} else if m.splitter.Virtual {
+			fmt.Printf("%s split into %d byte ranges\n", input, len(metadata.Ranges))
+		} else {
+			fmt.Printf("%s split into %d chunks in directory: %s\n", input, len(metadata.Chunks), metadata.SplitDir)
+		}
+		splits = append(splits, metadata)
+	}
+	return splits, nil
+}
+
+// loadMapTasks creates one map task per chunk file or byte range of the
+// job's input files. Every task records the input file it was split from
+// and the format its records are read as.
+func (m *MasterNode) loadMapTasks(job *Job, files []string, splits []*storage.InputFileMetadata, format storage.InputFormat) error {
+	for i, metadata := range splits {
+		inputs := make([]storage.InputRange, 0, len(metadata.Chunks)+len(metadata.Ranges))
+		for _, chunk := range metadata.Chunks {
+			inputs = append(inputs, storage.WholeFile(chunk))
+		}
+		inputs = append(inputs, metadata.Ranges...)
+
+		for _, input := range inputs {
+			task := &TaskResponse{
+				TaskID:    fmt.Sprintf("%s-map-%d", job.ID, len(job.pendingTasks)),
+				JobID:     job.ID,
+				TaskType:  "map",
+				InputPath: input.File,
+				OutputDir: filepath.Join(job.outputfilepath, "_intermediate"),
+				Metadata: map[string]string{
+					"numberOfReducers": fmt.Sprintf("%d", job.numberReducers),
+					"pluginFile":       job.pluginfilepath,
+					"sourceFile":       files[i],
+					"inputFormat":      format.Name(),
+				},
+			}
+			if input.Length >= 0 {
+				task.Metadata["inputOffset"] = strconv.FormatInt(input.Offset, 10)
+				task.Metadata["inputLength"] = strconv.FormatInt(input.Length, 10)
+			}
+			job.pendingTasks = append(job.pendingTasks, task)
+		}
+	}
+
+	if len(job.pendingTasks) == 0 {
+		return fmt.Errorf("no input records found to split")
+	}
+
+	job.phase = PhaseMap
+	return nil
+}
+
+// releaseSplits drops a job's references on split directories, which
+// removes the splits replaced since. Removing chunks can be slow on remote
+// storage, so it runs in the background.
+func (m *MasterNode) releaseSplits(splitDirs []string) {
+	if len(splitDirs) == 0 {
+		return
+	}
+	go func() {
+		if err := m.splitter.Release(splitDirs...); err != nil {
+			fmt.Printf("Failed to remove replaced splits: %v\n", err)
+		}
+	}()
+}
+
+// addJob registers a submitted job with the scheduler.
+func (m *MasterNode) addJob(job *Job) {
+	m.forgetFinishedJobs()
+	m.jobs[job.ID] = job
+	m.jobOrder = append(m.jobOrder, job)
+	for _, task := range job.pendingTasks {
+		m.tasks[task.TaskID] = task
+	}
+	m.stateChanged.Store(true)
+	fmt.Printf("Job %s submitted with %d input files, output in %s\n", job.ID, len(job.inputs), job.outputfilepath)
+}
+
+// inputRange returns the part of its input file a map task reads.
+func inputRange(task *TaskResponse) storage.InputRange {
+	offset, err1 := strconv.ParseInt(task.Metadata["inputOffset"], 10, 64)
+	length, err2 := strconv.ParseInt(task.Metadata["inputLength"], 10, 64)
+	if err1 != nil || err2 != nil {
+		return storage.WholeFile(task.InputPath)
+	}
+	return storage.InputRange{File: task.InputPath, Offset: offset, Length: length}
+}
+
+// running reports whether the job still has tasks to hand out or wait for.
+func (j *Job) running() bool {
+	return j.phase == PhaseMap || j.phase == PhaseReduce
+}
+
+// finished reports whether the job is done, failed or cancelled.
+func (j *Job) finished() bool {
+	return j.phase == PhaseDone || j.phase == PhaseFailed || j.phase == PhaseCancelled
+}
+
+// dropMapOutput forgets the intermediate files reported by a map task.
+func (j *Job) dropMapOutput(taskID string) {
+	for reducerID, path := range j.mapOutputs[taskID] {
+		files := j.reducerIntermediateFiles[reducerID]
+		for i, f := range files {
+			if f == path {
+				j.reducerIntermediateFiles[reducerID] = append(files[:i], files[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(j.mapOutputs, taskID)
+	delete(j.combinedRecords, taskID)
+}
+
+// totalCombinedRecords returns how many records the combiners of all
+// finished map tasks removed.
+func (j *Job) totalCombinedRecords() int64 {
+	var total int64
+	for _, n := range j.combinedRecords {
+		total += n
+	}
+	return total
+}
@@ -2,8 +2,18 @@ package master
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-mr/storage"
+	"go-mr/workerapi"
+
+	"google.golang.org/grpc"
 )
 
 type WorkerInfo struct {
@@ -11,6 +21,10 @@ type WorkerInfo struct {
 	Address string // Address of the worker node
 	Port    string // Port number for the worker node
 	Active  bool   // Indicates if the worker is currently active
+
+	missedHeartbeats int                       // Consecutive failed health checks
+	conn             *grpc.ClientConn          // Connection used for health checks
+	client           workerapi.WorkerApiClient // Client for the worker's WorkerApi
 }
 
 type ExecutionPhase int
@@ -20,163 +34,698 @@ const (
 	PhaseIdle
 	PhaseReduce
 	PhaseDone
+	PhaseFailed
+	PhaseCancelled
+	PhaseSplitting
 )
 
+func (p ExecutionPhase) String() string {
+	switch p {
+	case PhaseMap:
+		return "map"
+	case PhaseIdle:
+		return "idle"
+	case PhaseReduce:
+		return "reduce"
+	case PhaseDone:
+		return "done"
+	case PhaseFailed:
+		return "failed"
+	case PhaseCancelled:
+		return "cancelled"
+	case PhaseSplitting:
+		return "splitting"
+	}
+	return fmt.Sprintf("ExecutionPhase(%d)", int(p))
+}
+
+// DefaultMaxTaskAttempts is the number of times a task is handed out before
+// the job is failed.
+const DefaultMaxTaskAttempts = 4
+
+// DefaultTaskTimeout is how long a worker may hold a task before it is
+// considered lost and handed to another worker.
+const DefaultTaskTimeout = 60 * time.Second
+
+// leaseSweepInterval is how often the scheduler looks for expired leases.
+const leaseSweepInterval = time.Second
+
+// finishedJobsKept is how many done, failed or cancelled jobs the master
+// keeps answering status queries for. Older ones are forgotten as new jobs
+// are submitted.
+const finishedJobsKept = 100
+
+// DefaultHeartbeatInterval is how often the master probes every registered
+// worker through WorkerApi.HealthCheck.
+const DefaultHeartbeatInterval = 5 * time.Second
+
+// DefaultMaxMissedHeartbeats is the number of consecutive failed health
+// checks after which a worker is considered dead.
+const DefaultMaxMissedHeartbeats = 3
+
 type TaskRequest struct {
 	WorkerID string
 	ReplyCh  chan *TaskResponse
+	Refused  bool // Set before ReplyCh is closed if the worker must register again
 }
 
 type TaskResponse struct {
 	TaskID    string
+	JobID     string // Job the task belongs to
 	TaskType  string // e.g., "map" or "reduce"
 	InputPath string
+	OutputDir string // Directory the task writes its results to
 	Metadata  map[string]string
+	Attempt   int // Number of times the task has been handed out
 }
 
 type TaskStatusReport struct {
 	WorkerID          string
 	TaskID            string
+	Attempt           int // Attempt the report belongs to
 	Success           bool
 	Error             string
 	IntermediateFiles map[string]string // reducerID -> file path
+	CombinedRecords   int64             // Records the map-side combiner removed
+	FetchFailedTask   string            // Map task whose output a failed reduce couldn't fetch
+}
+
+// taskLease records which worker holds a task attempt and until when.
+type taskLease struct {
+	WorkerID string
+	Attempt  int
+	Deadline time.Time
 }
 
 type MasterNode struct {
-	workers                  map[string]*WorkerInfo
-	numberReducers           int // Number of reducers to use
-	inputfilepath            string
-	pluginfilepath           string
-	outputfilepath           string
-	requestChannel           chan *TaskRequest
-	taskSubmissionChannel    chan *TaskStatusReport // Channel for task submissions
-	phase                    ExecutionPhase
-	pendingTasks             []*TaskResponse
-	activeTasks              map[string]string   // taskID -> workerID
-	workerIdTaskMap          map[string][]string // workerID -> list of taskIDs
-	reducerIntermediateFiles map[string][]string // reducerID -> intermediate file paths
-}
-
-func NewMasterNode(inputFile, pluginFile, outputFile string, numberReducers int) *MasterNode {
+	workersMu             sync.Mutex // Guards workers and their heartbeat state
+	workers               map[string]*WorkerInfo
+	splitMu               sync.Mutex        // Serialises splits of submitted jobs
+	splitter              *storage.Splitter // Splits job inputs into map task chunks
+	storage               storage.Backend   // Backend holding splits and outputs
+	nextJobID             atomic.Int64      // Sequence number of the last submitted job
+	requestChannel        chan *TaskRequest
+	taskSubmissionChannel chan *TaskStatusReport   // Channel for task submissions
+	jobSubmissionChannel  chan *Job                // Jobs ready to be scheduled
+	splitDoneChannel      chan *splitResult        // Map tasks of jobs whose inputs were split
+	jobQueryChannel       chan *jobQuery           // Status, list and cancel requests
+	deadWorkerChannel     chan string              // Workers that stopped answering health checks
+	jobs                  map[string]*Job          // jobID -> job
+	jobOrder              []*Job                   // Jobs in submission order
+	tasks                 map[string]*TaskResponse // taskID -> original task definition, across jobs
+	workerIdTaskMap       map[string][]string      // workerID -> tasks running on it or whose map output it holds
+	maxTaskAttempts       int                      // Attempts per task before the job fails
+	taskTimeout           time.Duration            // Lease length of a task assignment
+	heartbeatInterval     time.Duration            // Time between health checks of a worker
+	maxMissedHeartbeats   int                      // Failed health checks before a worker is dead
+	statePath             string                   // File the scheduler state is saved to
+	savedState            []byte                   // Last state written to statePath
+	stateChanged          atomic.Bool              // Set when saved state changes, cleared once it is written
+}
+
+// NewMasterNode creates a master that splits the inputs of submitted jobs
+// with splitter and lists the chunks through the splitter's storage backend.
+func NewMasterNode(splitter *storage.Splitter) *MasterNode {
 	return &MasterNode{
-		workers:                  make(map[string]*WorkerInfo),
-		inputfilepath:            inputFile,
-		pluginfilepath:           pluginFile,
-		outputfilepath:           outputFile,
-		requestChannel:           make(chan *TaskRequest),
-		taskSubmissionChannel:    make(chan *TaskStatusReport),
-		workerIdTaskMap:          make(map[string][]string),
-		numberReducers:           numberReducers,
-		reducerIntermediateFiles: make(map[string][]string),
-		pendingTasks:             make([]*TaskResponse, 0),
-		activeTasks:              make(map[string]string),
-		phase:                    PhaseIdle,
-	}
-}
-
-func (m *MasterNode) RegisterWorker(workerID string, port string) {
-	worker := &WorkerInfo{
-		ID:     workerID,
-		Port:   port,
-		Active: true,
+		workers:               make(map[string]*WorkerInfo),
+		splitter:              splitter,
+		storage:               splitter.Storage,
+		requestChannel:        make(chan *TaskRequest),
+		taskSubmissionChannel: make(chan *TaskStatusReport),
+		jobSubmissionChannel:  make(chan *Job),
+		splitDoneChannel:      make(chan *splitResult),
+		jobQueryChannel:       make(chan *jobQuery),
+		deadWorkerChannel:     make(chan string),
+		jobs:                  make(map[string]*Job),
+		tasks:                 make(map[string]*TaskResponse),
+		workerIdTaskMap:       make(map[string][]string),
+		maxTaskAttempts:       DefaultMaxTaskAttempts,
+		taskTimeout:           DefaultTaskTimeout,
+		heartbeatInterval:     DefaultHeartbeatInterval,
+		maxMissedHeartbeats:   DefaultMaxMissedHeartbeats,
 	}
+}
 
-	m.workers[workerID] = worker
+// SetMaxTaskAttempts sets how many times a task may be attempted before the
+// whole job is failed. It must be called before StartScheduler.
+func (m *MasterNode) SetMaxTaskAttempts(attempts int) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	m.maxTaskAttempts = attempts
 }
 
-func (m *MasterNode) LoadMapTasksFromSplits(splitDir string) error {
-	files, err := os.ReadDir(splitDir)
-	if err != nil {
-		return fmt.Errorf("failed to read split directory: %w", err)
+// SetTaskTimeout sets how long a worker may hold a task before it is
+// re-queued. It must be called before StartScheduler.
+func (m *MasterNode) SetTaskTimeout(timeout time.Duration) {
+	m.taskTimeout = timeout
+}
+
+// SetHeartbeat sets how often workers are health checked and how many
+// consecutive misses mark a worker dead. It must be called before
+// StartScheduler.
+func (m *MasterNode) SetHeartbeat(interval time.Duration, maxMissed int) {
+	if maxMissed < 1 {
+		maxMissed = 1
 	}
+	m.heartbeatInterval = interval
+	m.maxMissedHeartbeats = maxMissed
+}
 
-	for i, f := range files {
-		// Skip directories
-		if f.IsDir() {
-			continue
-		}
+func (m *MasterNode) RegisterWorker(workerID string, address string, port string) {
+	m.workersMu.Lock()
+	defer m.workersMu.Unlock()
 
-		task := &TaskResponse{
-			TaskID:    fmt.Sprintf("map-%d", i),
-			TaskType:  "map",
-			InputPath: filepath.Join(splitDir, f.Name()),
-			Metadata: map[string]string{
-				"numberOfReducers": fmt.Sprintf("%d", m.numberReducers),
-				"pluginFile":       m.pluginfilepath,
-			},
-		}
-		m.pendingTasks = append(m.pendingTasks, task)
+	// A worker registering again gets a fresh connection for health checks
+	if old, ok := m.workers[workerID]; ok && old.conn != nil {
+		old.conn.Close()
 	}
 
-	if len(m.pendingTasks) == 0 {
-		return fmt.Errorf("no valid files found in split directory")
+	worker := &WorkerInfo{
+		ID:      workerID,
+		Address: address,
+		Port:    port,
+		Active:  true,
 	}
 
-	m.phase = PhaseMap
-	return nil
+	m.workers[workerID] = worker
+	m.stateChanged.Store(true)
+}
+
+// workerActive reports whether a worker is registered and hasn't been
+// declared dead.
+func (m *MasterNode) workerActive(workerID string) bool {
+	m.workersMu.Lock()
+	defer m.workersMu.Unlock()
+	w, ok := m.workers[workerID]
+	return ok && w.Active
+}
+
+// jobOf returns the job a task belongs to.
+func (m *MasterNode) jobOf(taskID string) (*Job, *TaskResponse, bool) {
+	task, ok := m.tasks[taskID]
+	if !ok {
+		return nil, nil, false
+	}
+	job, ok := m.jobs[task.JobID]
+	return job, task, ok
 }
 
 func (m *MasterNode) handleTaskStatusReport(report *TaskStatusReport) {
-	// Remove from active task tracking
-	delete(m.activeTasks, report.TaskID)
+	job, _, ok := m.jobOf(report.TaskID)
+
+	// Only the attempt currently holding the lease may report. Anything else
+	// is a late report from an attempt that already timed out.
+	var lease *taskLease
+	if ok {
+		lease, ok = job.activeTasks[report.TaskID]
+	}
+	if !ok || lease.Attempt != report.Attempt || lease.WorkerID != report.WorkerID {
+		fmt.Printf("Rejecting stale report for task %s attempt %d from %s\n", report.TaskID, report.Attempt, report.WorkerID)
+		return
+	}
+	m.stateChanged.Store(true)
+
+	// Remove from active task tracking. A finished map task stays tracked
+	// on its worker, which holds its output.
+	delete(job.activeTasks, report.TaskID)
+	if !report.Success || m.tasks[report.TaskID].TaskType != "map" {
+		m.untrackTask(report.WorkerID, report.TaskID)
+	}
+
+	if !job.running() {
+		fmt.Printf("Ignoring report for task %s, job %s has %s\n", report.TaskID, job.ID, job.phase)
+		return
+	}
 
 	if report.Success {
 		fmt.Printf("[✓] Task %s completed by %s\n", report.TaskID, report.WorkerID)
+		job.completedBy[report.TaskID] = report.WorkerID
+
+		if report.CombinedRecords > 0 {
+			job.combinedRecords[report.TaskID] = report.CombinedRecords
+		}
 
 		// Store intermediate files if any
 		if len(report.IntermediateFiles) > 0 {
+			job.mapOutputs[report.TaskID] = report.IntermediateFiles
 			for reducerID, filePath := range report.IntermediateFiles {
-				m.reducerIntermediateFiles[reducerID] = append(m.reducerIntermediateFiles[reducerID], filePath)
+				job.reducerIntermediateFiles[reducerID] = append(job.reducerIntermediateFiles[reducerID], filePath)
 			}
 		}
 
-		// Check if all map tasks are completed
-		if len(m.pendingTasks) == 0 && len(m.activeTasks) == 0 {
-			m.phase = PhaseIdle
+		// Advance the phase once every task of the current phase is done
+		if len(job.pendingTasks) == 0 && len(job.activeTasks) == 0 {
+			switch job.phase {
+			case PhaseMap:
+				m.scheduleReduceTasks(job)
+			case PhaseReduce:
+				m.completeJob(job)
+			}
 		}
 
+	} else if report.FetchFailedTask != "" {
+		fmt.Printf("[✗] Task %s on %s couldn't fetch the output of %s. Error: %s\n", report.TaskID, report.WorkerID, report.FetchFailedTask, report.Error)
+
+		m.handleFetchFailure(job, report.TaskID, report.FetchFailedTask, report.Error)
 	} else {
 		fmt.Printf("[✗] Task %s failed by %s. Error: %s\n", report.TaskID, report.WorkerID, report.Error)
 
-		// Simple re-queue for now (you can enhance this later)
-		failedTask := &TaskResponse{
-			TaskID:    report.TaskID,
-			TaskType:  "map",
-			InputPath: "", // TODO: store original info for proper retry
-			Metadata:  map[string]string{},
+		m.retryTask(job, report.TaskID, report.Error)
+	}
+}
+
+// handleFetchFailure re-runs a map task whose output a reduce task couldn't
+// fetch. The worker holding the output is usually dead, but health checks
+// take several heartbeats to notice, so the map output is rebuilt right away.
+// The reduce attempt isn't counted against the reduce task, which is
+// scheduled again once the map output is back. A map task whose output keeps
+// getting lost fails the job once it has used all its attempts.
+func (m *MasterNode) handleFetchFailure(job *Job, reduceTaskID string, mapTaskID string, reason string) {
+	mapTask, ok := m.tasks[mapTaskID]
+	workerID, done := job.completedBy[mapTaskID]
+	if !ok || mapTask.JobID != job.ID || mapTask.TaskType != "map" || (!done && job.phase != PhaseMap) {
+		m.retryTask(job, reduceTaskID, reason)
+		return
+	}
+	job.uncountedAttempts[reduceTaskID]++
+
+	// Another reduce task already reported this output lost, and the job is
+	// back in the map phase rebuilding it
+	if !done {
+		return
+	}
+
+	if mapTask.Attempt >= m.maxTaskAttempts {
+		m.failJob(job, fmt.Sprintf("output of map task %s was lost after %d attempts, last error: %s", mapTaskID, mapTask.Attempt, reason))
+		return
+	}
+	m.untrackTask(workerID, mapTaskID)
+	m.rerunMaps(job, []string{mapTaskID}, fmt.Sprintf("its output couldn't be fetched from worker %s", workerID))
+}
+
+// retryTask re-queues the original definition of a task so it runs again
+// with the same inputs, or fails the job once it has used all its attempts.
+// Attempts that ended through no fault of the task, such as a reduce whose
+// map output was lost, don't count.
+func (m *MasterNode) retryTask(job *Job, taskID string, reason string) {
+	task, ok := m.tasks[taskID]
+	if !ok {
+		fmt.Printf("Ignoring failure of unknown task %s\n", taskID)
+		return
+	}
+	job.failedAttempts[taskID]++
+
+	if attempts := task.Attempt - job.uncountedAttempts[taskID]; attempts >= m.maxTaskAttempts {
+		m.failJob(job, fmt.Sprintf("task %s failed after %d attempts, last error: %s", task.TaskID, attempts, reason))
+		return
+	}
+
+	job.pendingTasks = append(job.pendingTasks, task)
+}
+
+// expireLeases re-queues every task whose lease ran out before its worker
+// reported back.
+func (m *MasterNode) expireLeases(now time.Time) {
+	for _, job := range m.jobOrder {
+		for taskID, lease := range job.activeTasks {
+			if now.Before(lease.Deadline) {
+				continue
+			}
+
+			fmt.Printf("[!] Task %s attempt %d on %s timed out\n", taskID, lease.Attempt, lease.WorkerID)
+			delete(job.activeTasks, taskID)
+			m.stateChanged.Store(true)
+			m.untrackTask(lease.WorkerID, taskID)
+			if !job.running() {
+				continue
+			}
+			m.retryTask(job, taskID, fmt.Sprintf("timed out after %s on worker %s", m.taskTimeout, lease.WorkerID))
 		}
-		m.pendingTasks = append(m.pendingTasks, failedTask)
 	}
 }
 
-func (m *MasterNode) StartScheduler() {
-	go func() {
-		for {
-			select {
-			case taskReq := <-m.requestChannel:
-				if m.phase == PhaseMap && len(m.pendingTasks) > 0 {
-					task := m.pendingTasks[0]
-					m.pendingTasks = m.pendingTasks[1:]
-
-					m.activeTasks[task.TaskID] = taskReq.WorkerID
-					m.workerIdTaskMap[taskReq.WorkerID] = append(m.workerIdTaskMap[taskReq.WorkerID], task.TaskID)
-					taskReq.ReplyCh <- task
-				} else if m.phase == PhaseReduce {
-					// Similar logic for reduce tasks
-				} else {
-					// Idle / No tasks available
-					close(taskReq.ReplyCh)
-				}
+// failJob stops scheduling the job and records why it failed. Other jobs
+// keep running.
+func (m *MasterNode) failJob(job *Job, reason string) {
+	job.phase = PhaseFailed
+	job.failureReason = reason
+	job.pendingTasks = nil
+	m.retireJob(job)
+	fmt.Printf("[✗] Job %s failed: %s\n", job.ID, reason)
+}
+
+// completeJob marks a job done once its last reduce task finished.
+func (m *MasterNode) completeJob(job *Job) {
+	job.phase = PhaseDone
+	m.retireJob(job)
+	fmt.Printf("[✓] Job %s completed, output written to %s\n", job.ID, job.outputfilepath)
+}
+
+// retireJob releases everything a finished job holds. Its final status is
+// kept for status queries, while its tasks and their bookkeeping are
+// dropped, so a long running master only grows with the jobs still running.
+// Reports that still arrive for its tasks are rejected as stale.
+func (m *MasterNode) retireJob(job *Job) {
+	m.untrackJob(job)
+	m.releaseSplits(job.splitDirs)
+	job.splitDirs = nil
+
+	status := m.jobStatus(job)
+	job.finalStatus = &status
+	for taskID, task := range m.tasks {
+		if task.JobID == job.ID {
+			delete(m.tasks, taskID)
+		}
+	}
+	job.pendingTasks = nil
+	clear(job.activeTasks)
+	clear(job.reducerIntermediateFiles)
+	clear(job.completedBy)
+	clear(job.mapOutputs)
+	clear(job.combinedRecords)
+	clear(job.failedAttempts)
+	clear(job.uncountedAttempts)
+}
+
+// forgetFinishedJobs drops the oldest finished jobs once more than
+// finishedJobsKept of them are kept. It must not run while the scheduler
+// iterates over jobOrder.
+func (m *MasterNode) forgetFinishedJobs() {
+	finished := 0
+	for _, job := range m.jobOrder {
+		if job.finished() {
+			finished++
+		}
+	}
+	if finished <= finishedJobsKept {
+		return
+	}
+
+	kept := m.jobOrder[:0]
+	for _, job := range m.jobOrder {
+		if finished > finishedJobsKept && job.finished() {
+			finished--
+			delete(m.jobs, job.ID)
+			continue
+		}
+		kept = append(kept, job)
+	}
+	clear(m.jobOrder[len(kept):])
+	m.jobOrder = kept
+}
+
+// trackTask records that a task runs on a worker, once however often the
+// worker is given the task.
+func (m *MasterNode) trackTask(workerID string, taskID string) {
+	for _, id := range m.workerIdTaskMap[workerID] {
+		if id == taskID {
+			return
+		}
+	}
+	m.workerIdTaskMap[workerID] = append(m.workerIdTaskMap[workerID], taskID)
+}
+
+// untrackTask forgets a task of a worker that neither runs it nor holds its
+// output anymore.
+func (m *MasterNode) untrackTask(workerID string, taskID string) {
+	taskIDs := m.workerIdTaskMap[workerID]
+	for i, id := range taskIDs {
+		if id == taskID {
+			taskIDs = append(taskIDs[:i], taskIDs[i+1:]...)
+			break
+		}
+	}
+	if len(taskIDs) == 0 {
+		delete(m.workerIdTaskMap, workerID)
+		return
+	}
+	m.workerIdTaskMap[workerID] = taskIDs
+}
+
+// untrackJob forgets the tasks of a job that stopped running on every
+// worker, so a worker dying later doesn't bring them back.
+func (m *MasterNode) untrackJob(job *Job) {
+	for workerID, taskIDs := range m.workerIdTaskMap {
+		kept := taskIDs[:0]
+		for _, taskID := range taskIDs {
+			if task, ok := m.tasks[taskID]; !ok || task.JobID != job.ID {
+				kept = append(kept, taskID)
 			}
 		}
-	}()
+		if len(kept) == 0 {
+			delete(m.workerIdTaskMap, workerID)
+		} else {
+			m.workerIdTaskMap[workerID] = kept
+		}
+	}
+}
 
+// scheduleReduceTasks turns the collected intermediate files into one reduce
+// task per partition and moves the job into the reduce phase.
+func (m *MasterNode) scheduleReduceTasks(job *Job) {
+	mapSources := m.mapSources(job)
+
+	scheduled := 0
+	for r := 0; r < job.numberReducers; r++ {
+		reducerID := strconv.Itoa(r)
+		taskID := fmt.Sprintf("%s-reduce-%d", job.ID, r)
+
+		// Reduce output lives in the output directory, not on the worker,
+		// so a finished reduce never has to run again.
+		if _, done := job.completedBy[taskID]; done {
+			continue
+		}
+
+		files := append([]string(nil), job.reducerIntermediateFiles[reducerID]...)
+		sort.Strings(files)
+
+		// Keep an existing definition so its attempt count carries over
+		task, ok := m.tasks[taskID]
+		if !ok {
+			task = &TaskResponse{
+				TaskID:    taskID,
+				JobID:     job.ID,
+				TaskType:  "reduce",
+				OutputDir: job.outputfilepath,
+			}
+			m.tasks[taskID] = task
+		}
+		task.Metadata = map[string]string{
+			"reducerID":         reducerID,
+			"intermediateFiles": strings.Join(files, ","),
+			"pluginFile":        job.pluginfilepath,
+		}
+		if mapSources != "" {
+			task.Metadata["mapSources"] = mapSources
+		}
+		job.pendingTasks = append(job.pendingTasks, task)
+		scheduled++
+	}
+
+	if scheduled == 0 {
+		m.completeJob(job)
+		return
+	}
+
+	if saved := job.totalCombinedRecords(); saved > 0 {
+		fmt.Printf("Combiner saved %d records of job %s from being shuffled\n", saved, job.ID)
+	}
+	fmt.Printf("All map tasks of job %s completed, scheduling %d reduce tasks\n", job.ID, scheduled)
+	job.phase = PhaseReduce
+}
+
+// mapSources lists every finished map task of the job with the address of
+// the worker that serves its output, as taskID=host:port entries. It returns
+// an empty string if a worker can't be found, in which case reducers read
+// the intermediate files from storage instead.
+func (m *MasterNode) mapSources(job *Job) string {
+	taskIDs := make([]string, 0, len(job.mapOutputs))
+	for taskID := range job.mapOutputs {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	m.workersMu.Lock()
+	defer m.workersMu.Unlock()
+
+	sources := make([]string, 0, len(taskIDs))
+	for _, taskID := range taskIDs {
+		worker, ok := m.workers[job.completedBy[taskID]]
+		if !ok {
+			return ""
+		}
+		sources = append(sources, taskID+"="+net.JoinHostPort(worker.Address, worker.Port))
+	}
+	return strings.Join(sources, ",")
+}
+
+// handleDeadWorker re-queues every task the worker was running together with
+// the finished map tasks whose intermediate output lived on it, job by job.
+func (m *MasterNode) handleDeadWorker(workerID string) {
+	taskIDs := m.workerIdTaskMap[workerID]
+	delete(m.workerIdTaskMap, workerID)
+	m.stateChanged.Store(true)
+
+	// State saved by older masters may list a task more than once
+	seen := make(map[string]bool, len(taskIDs))
+	byJob := make(map[*Job][]string)
+	for _, taskID := range taskIDs {
+		if seen[taskID] {
+			continue
+		}
+		seen[taskID] = true
+		if job, _, ok := m.jobOf(taskID); ok {
+			byJob[job] = append(byJob[job], taskID)
+		}
+	}
+	for _, job := range m.jobOrder {
+		if ids, ok := byJob[job]; ok {
+			m.recoverJobFromDeadWorker(job, workerID, ids)
+		}
+	}
+}
+
+// recoverJobFromDeadWorker re-queues the tasks of one job that ran on a dead
+// worker. Losing map output during the reduce phase sends the job back to
+// the map phase, and the unfinished reduce tasks are scheduled again once
+// the maps are redone.
+func (m *MasterNode) recoverJobFromDeadWorker(job *Job, workerID string, taskIDs []string) {
+	if !job.running() {
+		return
+	}
+
+	var lostMaps []string
+	for _, taskID := range taskIDs {
+		if lease, ok := job.activeTasks[taskID]; ok && lease.WorkerID == workerID {
+			delete(job.activeTasks, taskID)
+			m.retryTask(job, taskID, fmt.Sprintf("worker %s stopped responding to health checks", workerID))
+			continue
+		}
+
+		task, ok := m.tasks[taskID]
+		if ok && task.TaskType == "map" && job.completedBy[taskID] == workerID {
+			lostMaps = append(lostMaps, taskID)
+		}
+	}
+
+	if job.phase == PhaseFailed || len(lostMaps) == 0 {
+		return
+	}
+	m.rerunMaps(job, lostMaps, fmt.Sprintf("its output was on dead worker %s", workerID))
+}
+
+// rerunMaps re-queues finished map tasks whose output was lost. Losing map
+// output during the reduce phase sends the job back to the map phase.
+func (m *MasterNode) rerunMaps(job *Job, taskIDs []string, reason string) {
+	for _, taskID := range taskIDs {
+		fmt.Printf("[!] Re-running map task %s, %s\n", taskID, reason)
+		delete(job.completedBy, taskID)
+		job.dropMapOutput(taskID)
+		job.pendingTasks = append(job.pendingTasks, m.tasks[taskID])
+	}
+
+	if job.phase == PhaseReduce {
+		// Reduce tasks would read incomplete input, so pull them back until
+		// the lost map output is rebuilt. Reports from reduce attempts still
+		// in flight are rejected because their leases are gone, and those
+		// attempts don't count against their tasks.
+		remaining := job.pendingTasks[:0]
+		for _, task := range job.pendingTasks {
+			if task.TaskType != "reduce" {
+				remaining = append(remaining, task)
+			}
+		}
+		job.pendingTasks = remaining
+
+		for taskID, lease := range job.activeTasks {
+			if m.tasks[taskID].TaskType == "reduce" {
+				delete(job.activeTasks, taskID)
+				m.untrackTask(lease.WorkerID, taskID)
+				job.uncountedAttempts[taskID]++
+			}
+		}
+
+		fmt.Printf("Returning job %s to map phase to rebuild lost map output\n", job.ID)
+		job.phase = PhaseMap
+	}
+}
+
+// StartScheduler starts the scheduling loop and the worker health checks.
+// The scheduling loop is the only goroutine that touches job, task and
+// phase state; gRPC handlers and the health checker reach it through
+// channels. After every event that changed it the loop saves the state.
+func (m *MasterNode) StartScheduler() {
 	go func() {
+		sweep := time.NewTicker(leaseSweepInterval)
+		defer sweep.Stop()
+
 		for {
 			select {
+			case taskReq := <-m.requestChannel:
+				m.assignTask(taskReq)
 			case taskStatus := <-m.taskSubmissionChannel:
 				m.handleTaskStatusReport(taskStatus)
+			case job := <-m.jobSubmissionChannel:
+				m.addJob(job)
+			case result := <-m.splitDoneChannel:
+				m.startJob(result)
+			case query := <-m.jobQueryChannel:
+				m.answerJobQuery(query)
+			case now := <-sweep.C:
+				m.expireLeases(now)
+			case workerID := <-m.deadWorkerChannel:
+				m.handleDeadWorker(workerID)
 			}
+
+			m.saveState()
 		}
 	}()
+
+	go m.monitorWorkers()
+}
+
+// assignTask hands the next pending task of the oldest running job to the
+// requesting worker, or closes the reply channel when there is nothing to
+// run. Workers that aren't registered, or were declared dead, are refused
+// until they register again: their tasks were already re-queued and health
+// checks stopped counting their misses, so work given to them could be lost
+// without anyone noticing.
+func (m *MasterNode) assignTask(taskReq *TaskRequest) {
+	if !m.workerActive(taskReq.WorkerID) {
+		taskReq.Refused = true
+		close(taskReq.ReplyCh)
+		return
+	}
+
+	var job *Job
+	for _, j := range m.jobOrder {
+		if j.running() && len(j.pendingTasks) > 0 {
+			job = j
+			break
+		}
+	}
+	if job == nil {
+		// Idle / No tasks available
+		close(taskReq.ReplyCh)
+		return
+	}
+
+	task := job.pendingTasks[0]
+	job.pendingTasks = job.pendingTasks[1:]
+	task.Attempt++
+	m.stateChanged.Store(true)
+
+	job.activeTasks[task.TaskID] = &taskLease{
+		WorkerID: taskReq.WorkerID,
+		Attempt:  task.Attempt,
+		Deadline: time.Now().Add(m.taskTimeout),
+	}
+	m.trackTask(taskReq.WorkerID, task.TaskID)
+
+	// Hand out a copy so later retries don't modify a task in flight. The
+	// reply channel is buffered, so a caller that already gave up can't
+	// block the scheduler; its lease simply expires.
+	assigned := *task
+	taskReq.ReplyCh <- &assigned
 }
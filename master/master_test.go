@@ -0,0 +1,594 @@
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go-mr/masterapi"
+	"go-mr/storage"
+	"go-mr/workerapi"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestMaster returns a master whose storage lives in a temporary
+// directory. Its scheduler isn't started.
+func newTestMaster(t *testing.T) *MasterNode {
+	t.Helper()
+	root := t.TempDir()
+	splitter, err := storage.NewSplitter(64, root, filepath.Join(root, "metadata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMasterNode(splitter)
+}
+
+// addTestJob registers a job with nMaps map tasks and one reducer.
+func addTestJob(m *MasterNode, id string, nMaps int) *Job {
+	job := newJob(id, JobSpec{NumberReducers: 1, OutputDir: "out"})
+	for i := 0; i < nMaps; i++ {
+		job.pendingTasks = append(job.pendingTasks, &TaskResponse{
+			TaskID:   fmt.Sprintf("%s-map-%d", id, i),
+			JobID:    id,
+			TaskType: "map",
+			Metadata: map[string]string{},
+		})
+	}
+	job.phase = PhaseMap
+	m.addJob(job)
+	return job
+}
+
+// assign hands the next task to a worker, or returns nil if there is none.
+// A worker the master doesn't know yet is registered first.
+func assign(m *MasterNode, workerID string) *TaskResponse {
+	m.workersMu.Lock()
+	_, known := m.workers[workerID]
+	m.workersMu.Unlock()
+	if !known {
+		m.RegisterWorker(workerID, "127.0.0.1", "0")
+	}
+
+	req := &TaskRequest{WorkerID: workerID, ReplyCh: make(chan *TaskResponse, 1)}
+	m.assignTask(req)
+	return <-req.ReplyCh
+}
+
+// report sends the result of an attempt as its worker would.
+func report(m *MasterNode, workerID string, task *TaskResponse, success bool) {
+	r := &TaskStatusReport{WorkerID: workerID, TaskID: task.TaskID, Attempt: task.Attempt, Success: success}
+	if success && task.TaskType == "map" {
+		r.IntermediateFiles = map[string]string{"0": "mr-" + task.TaskID}
+	}
+	if !success {
+		r.Error = "test failure"
+	}
+	m.handleTaskStatusReport(r)
+}
+
+func TestDeadWorkerRequeuesRetriedTaskOnce(t *testing.T) {
+	m := newTestMaster(t)
+	job := addTestJob(m, "job-1", 2)
+
+	// w1 fails map-0, gets it again and succeeds
+	task := assign(m, "w1")
+	report(m, "w1", task, false)
+	other := assign(m, "w2")
+	task = assign(m, "w1")
+	if task.TaskID != "job-1-map-0" || task.Attempt != 2 {
+		t.Fatalf("got %s attempt %d, want job-1-map-0 attempt 2", task.TaskID, task.Attempt)
+	}
+	report(m, "w1", task, true)
+	if got := m.workerIdTaskMap["w1"]; len(got) != 1 {
+		t.Fatalf("w1 tracks %v, want only job-1-map-0", got)
+	}
+
+	m.handleDeadWorker("w1")
+	var requeued []string
+	for _, task := range job.pendingTasks {
+		requeued = append(requeued, task.TaskID)
+	}
+	if len(requeued) != 1 || requeued[0] != "job-1-map-0" {
+		t.Fatalf("pending tasks after w1 died: %v, want [job-1-map-0]", requeued)
+	}
+
+	// Finishing the job forgets every task it tracked
+	report(m, "w2", other, true)
+	task = assign(m, "w2")
+	report(m, "w2", task, true)
+	task = assign(m, "w2")
+	if task.TaskType != "reduce" {
+		t.Fatalf("got %s task %s, want the reduce task", task.TaskType, task.TaskID)
+	}
+	report(m, "w2", task, true)
+	if job.phase != PhaseDone {
+		t.Fatalf("job is %s, want done", job.phase)
+	}
+	if len(m.workerIdTaskMap) != 0 {
+		t.Fatalf("finished job still tracked on workers: %v", m.workerIdTaskMap)
+	}
+}
+
+func TestDeadWorkerMustRegisterAgain(t *testing.T) {
+	m := newTestMaster(t)
+	m.SetHeartbeat(time.Hour, 1)
+	job := addTestJob(m, "job-1", 2)
+	server := NewMasterApiServer(m)
+	m.StartScheduler()
+	ctx := context.Background()
+
+	register := func() {
+		t.Helper()
+		if _, err := server.RegisterWorker(ctx, &masterapi.RegisterWorkerRequest{
+			Workerid: "w1", Workeraddress: "127.0.0.1", Workerport: "1",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requestTask := func() (*masterapi.TaskResponse, error) {
+		return server.RequestTask(ctx, &masterapi.TaskRequest{Workerid: "w1"})
+	}
+
+	// A worker the master doesn't know is told to register
+	if _, err := requestTask(); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("unregistered worker got %v, want FailedPrecondition", err)
+	}
+	register()
+	if task, err := requestTask(); err != nil || task.GetTaskid() != "job-1-map-0" {
+		t.Fatalf("registered worker got %v, %v, want job-1-map-0", task, err)
+	}
+
+	// Once declared dead its task goes back to the queue and it gets no
+	// more work until it registers again, which makes it a worker that
+	// health checks watch once more
+	m.workersMu.Lock()
+	w1 := m.workers["w1"]
+	m.workersMu.Unlock()
+	if dead := m.recordHeartbeats([]*WorkerInfo{w1}, []bool{false}); len(dead) != 1 {
+		t.Fatalf("recordHeartbeats declared %v dead, want w1", dead)
+	}
+	m.deadWorkerChannel <- "w1"
+	if _, err := requestTask(); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("dead worker got %v, want FailedPrecondition", err)
+	}
+	register()
+	if task, err := requestTask(); err != nil || task.GetTaskid() != "job-1-map-1" {
+		t.Fatalf("re-registered worker got %v, %v, want job-1-map-1", task, err)
+	}
+	jobStatus, err := m.JobStatus(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := jobStatus.MapTasks; counts.Pending != 1 || counts.Active != 1 {
+		t.Fatalf("map tasks %+v after w1 died and came back, want 1 pending and 1 active", counts)
+	}
+}
+
+// reportFetchFailure reports that a reduce attempt couldn't fetch the output
+// of a map task.
+func reportFetchFailure(m *MasterNode, workerID string, task *TaskResponse, mapTaskID string) {
+	m.handleTaskStatusReport(&TaskStatusReport{
+		WorkerID:        workerID,
+		TaskID:          task.TaskID,
+		Attempt:         task.Attempt,
+		Error:           "connection refused",
+		FetchFailedTask: mapTaskID,
+	})
+}
+
+func TestFetchFailureRerunsLostMapTask(t *testing.T) {
+	m := newTestMaster(t)
+	m.SetMaxTaskAttempts(2)
+	job := addTestJob(m, "job-1", 2)
+
+	map0 := assign(m, "w1")
+	map1 := assign(m, "w2")
+	report(m, "w1", map0, true)
+	report(m, "w2", map1, true)
+
+	// w1 is gone but not yet declared dead, so the reducer can't fetch
+	// map-0 from it. Every reducer attempt fails this way, more often than
+	// a task may fail, yet the job must not fail.
+	for round, lost := range []string{"job-1-map-0", "job-1-map-1"} {
+		reduce := assign(m, "w3")
+		if reduce.TaskType != "reduce" || reduce.Attempt != round+1 {
+			t.Fatalf("got %s attempt %d, want the reduce task attempt %d", reduce.TaskID, reduce.Attempt, round+1)
+		}
+		reportFetchFailure(m, "w3", reduce, lost)
+		if job.phase != PhaseMap {
+			t.Fatalf("job is %s after a fetch failure, want map", job.phase)
+		}
+		if _, done := job.completedBy[lost]; done {
+			t.Fatalf("%s is still completed after its output was lost", lost)
+		}
+		if len(job.pendingTasks) != 1 || job.pendingTasks[0].TaskID != lost {
+			t.Fatalf("pending tasks after a fetch failure: %v, want only %s", job.pendingTasks, lost)
+		}
+
+		rerun := assign(m, "w3")
+		report(m, "w3", rerun, true)
+		if job.phase != PhaseReduce {
+			t.Fatalf("job is %s after %s ran again, want reduce", job.phase, lost)
+		}
+	}
+
+	// Only the attempts that failed on their own count, so one real
+	// failure is still retried
+	reduce := assign(m, "w3")
+	if reduce.Attempt != 3 {
+		t.Fatalf("got reduce attempt %d, want 3", reduce.Attempt)
+	}
+	report(m, "w3", reduce, false)
+	if job.phase != PhaseReduce {
+		t.Fatalf("job is %s after the first real reduce failure, want reduce: %s", job.phase, job.failureReason)
+	}
+	reduce = assign(m, "w3")
+	report(m, "w3", reduce, true)
+	if job.phase != PhaseDone {
+		t.Fatalf("job is %s, want done: %s", job.phase, job.failureReason)
+	}
+}
+
+func TestFetchFailureFailsJobAfterMapAttempts(t *testing.T) {
+	m := newTestMaster(t)
+	m.SetMaxTaskAttempts(2)
+	job := addTestJob(m, "job-1", 1)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		task := assign(m, "w1")
+		if task.TaskType != "map" || task.Attempt != attempt {
+			t.Fatalf("got %s attempt %d, want the map task attempt %d", task.TaskID, task.Attempt, attempt)
+		}
+		report(m, "w1", task, true)
+		reduce := assign(m, "w2")
+		reportFetchFailure(m, "w2", reduce, "job-1-map-0")
+	}
+	if job.phase != PhaseFailed {
+		t.Fatalf("job is %s after its map output was lost on every attempt, want failed", job.phase)
+	}
+}
+
+func TestJobCancelledWhileSplittingStaysCancelled(t *testing.T) {
+	m := newTestMaster(t)
+	job := newJob("job-1", JobSpec{NumberReducers: 1, OutputDir: "out"})
+	job.phase = PhaseSplitting
+	m.addJob(job)
+
+	m.cancelJob(job)
+	if job.phase != PhaseCancelled {
+		t.Fatalf("job is %s after cancel, want cancelled", job.phase)
+	}
+	task := &TaskResponse{TaskID: "job-1-map-0", JobID: "job-1", TaskType: "map", Metadata: map[string]string{}}
+	m.startJob(&splitResult{JobID: "job-1", Tasks: []*TaskResponse{task}})
+	if job.phase != PhaseCancelled || len(job.pendingTasks) != 0 {
+		t.Fatalf("job is %s with %d pending tasks after its split finished, want cancelled with none", job.phase, len(job.pendingTasks))
+	}
+	if got := assign(m, "w1"); got != nil {
+		t.Fatalf("worker was given %s of a cancelled job", got.TaskID)
+	}
+}
+
+func TestFinishedJobsArePruned(t *testing.T) {
+	m := newTestMaster(t)
+	job := addTestJob(m, "job-1", 2)
+	for i := 0; i < 3; i++ {
+		task := assign(m, "w1")
+		report(m, "w1", task, true)
+	}
+	if job.phase != PhaseDone {
+		t.Fatalf("job is %s, want done", job.phase)
+	}
+	if len(m.tasks) != 0 || len(job.completedBy) != 0 || len(job.mapOutputs) != 0 {
+		t.Fatalf("finished job still holds %d tasks, %d completions and %d map outputs", len(m.tasks), len(job.completedBy), len(job.mapOutputs))
+	}
+	status := m.jobStatus(job)
+	if status.Phase != PhaseDone || status.MapTasks.Done != 2 || status.ReduceTasks.Done != 1 {
+		t.Fatalf("status of the finished job %+v, want 2 maps and 1 reduce done", status)
+	}
+
+	// Only the newest finished jobs are kept once more are submitted
+	for i := 2; i <= finishedJobsKept+1; i++ {
+		m.cancelJob(addTestJob(m, fmt.Sprintf("job-%d", i), 1))
+	}
+	addTestJob(m, "job-last", 1)
+	if _, ok := m.jobs["job-1"]; ok {
+		t.Fatal("oldest finished job was kept")
+	}
+	if len(m.jobs) != finishedJobsKept+1 || len(m.jobOrder) != len(m.jobs) || m.jobOrder[0].ID != "job-2" {
+		t.Fatalf("kept %d jobs starting with %s, want %d starting with job-2", len(m.jobs), m.jobOrder[0].ID, finishedJobsKept+1)
+	}
+	if len(m.tasks) != 1 {
+		t.Fatalf("master holds %d tasks, want only the one of job-last", len(m.tasks))
+	}
+}
+
+func TestSaveStateOnlyAfterChanges(t *testing.T) {
+	m := newTestMaster(t)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	m.SetStatePath(statePath)
+	job := addTestJob(m, "job-1", 1)
+	m.saveState()
+
+	saved := func() *masterState {
+		t.Helper()
+		data, err := os.ReadFile(statePath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var state masterState
+		if err := json.Unmarshal(data, &state); err != nil {
+			t.Fatal(err)
+		}
+		return &state
+	}
+	if state := saved(); state == nil || len(state.Jobs) != 1 {
+		t.Fatalf("saved state %+v, want job-1", state)
+	}
+
+	// Neither an idle sweep nor a status query rewrites the file
+	os.Remove(statePath)
+	m.expireLeases(time.Now())
+	m.saveState()
+	m.answerJobQuery(&jobQuery{ReplyCh: make(chan jobQueryResult, 1)})
+	m.saveState()
+	if saved() != nil {
+		t.Fatal("state was saved although nothing changed")
+	}
+
+	// A finished job is left out of the snapshot
+	task := assign(m, "w1")
+	report(m, "w1", task, true)
+	task = assign(m, "w1")
+	report(m, "w1", task, true)
+	if job.phase != PhaseDone {
+		t.Fatalf("job is %s, want done", job.phase)
+	}
+	m.saveState()
+	if state := saved(); state == nil || len(state.Jobs) != 0 || state.NextJobID != m.nextJobID.Load() {
+		t.Fatalf("saved state %+v, want no jobs", state)
+	}
+}
+
+// healthyWorker answers the master's health checks.
+type healthyWorker struct {
+	workerapi.UnimplementedWorkerApiServer
+}
+
+func (healthyWorker) HealthCheck(context.Context, *workerapi.HealthCheckRequest) (*workerapi.HealthCheckResponse, error) {
+	return &workerapi.HealthCheckResponse{Healthy: true}, nil
+}
+
+// startWorkerApi serves health checks on a free local port and returns it.
+func startWorkerApi(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	workerapi.RegisterWorkerApiServer(server, healthyWorker{})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return strconv.Itoa(lis.Addr().(*net.TCPAddr).Port)
+}
+
+// closedPort returns a local port nothing listens on.
+func closedPort(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return strconv.Itoa(lis.Addr().(*net.TCPAddr).Port)
+}
+
+// runWorker requests tasks and reports them done until stop is closed, the
+// way a registered worker talks to the master. Like a worker it registers
+// again when the master declared it dead.
+func runWorker(ctx context.Context, server *MasterApiServer, registration *masterapi.RegisterWorkerRequest, stop <-chan struct{}) error {
+	workerID := registration.GetWorkerid()
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		task, err := server.RequestTask(ctx, &masterapi.TaskRequest{Workerid: workerID})
+		if status.Code(err) == codes.FailedPrecondition {
+			if _, err := server.RegisterWorker(ctx, registration); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if task.GetTasktype() == "none" {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+
+		report := &masterapi.TaskStatusReport{
+			Workerid: workerID,
+			Taskid:   task.GetTaskid(),
+			Attempt:  task.GetAttempt(),
+			Success:  true,
+		}
+		if task.GetTasktype() == "map" {
+			report.Intermediatefiles = map[string]string{
+				"0": "mr-" + task.GetTaskid() + "-0",
+				"1": "mr-" + task.GetTaskid() + "-1",
+			}
+		}
+		if _, err := server.ReportTaskStatus(ctx, report); err != nil {
+			return err
+		}
+	}
+}
+
+// TestConcurrentJobsWithSimulatedWorkers drives the master through its gRPC
+// handlers with several workers, concurrent submissions, status queries,
+// heartbeats and state saving all at once. Run it with -race.
+func TestConcurrentJobsWithSimulatedWorkers(t *testing.T) {
+	m := newTestMaster(t)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	m.SetStatePath(statePath)
+	m.SetHeartbeat(50*time.Millisecond, 3)
+	m.StartScheduler()
+	server := NewMasterApiServer(m)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	plugin := filepath.Join(dir, "plugin.so")
+	if err := os.WriteFile(plugin, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var inputs []string
+	for i := 0; i < 2; i++ {
+		input := filepath.Join(dir, fmt.Sprintf("input-%d.txt", i))
+		var data []byte
+		for line := 0; line < 50; line++ {
+			data = fmt.Appendf(data, "input %d line %d\n", i, line)
+		}
+		if err := os.WriteFile(input, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input)
+	}
+
+	// Five workers run tasks. A sixth takes a task and vanishes without
+	// answering health checks, so its task must be re-queued.
+	stop := make(chan struct{})
+	errs := make(chan error, 8)
+	var workers sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		workerID := fmt.Sprintf("w%d", i)
+		registration := &masterapi.RegisterWorkerRequest{
+			Workerid: workerID, Workeraddress: "127.0.0.1", Workerport: startWorkerApi(t),
+		}
+		if _, err := server.RegisterWorker(ctx, registration); err != nil {
+			t.Fatal(err)
+		}
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := runWorker(ctx, server, registration, stop); err != nil {
+				errs <- fmt.Errorf("worker %s: %v", workerID, err)
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		workers.Wait()
+	}()
+
+	var submitted sync.WaitGroup
+	jobIDs := make([]string, len(inputs))
+	for i, input := range inputs {
+		submitted.Add(1)
+		go func() {
+			defer submitted.Done()
+			resp, err := server.SubmitJob(ctx, &masterapi.SubmitJobRequest{
+				Inputs: []string{input}, Plugin: plugin, Reducers: 2, Outputdir: filepath.Join(dir, "out", strconv.Itoa(i)),
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			jobIDs[i] = resp.GetJobid()
+		}()
+	}
+
+	if _, err := server.RegisterWorker(ctx, &masterapi.RegisterWorkerRequest{
+		Workerid: "ghost", Workeraddress: "127.0.0.1", Workerport: closedPort(t),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var ghostTask string
+	for ghostTask == "" {
+		task, err := server.RequestTask(ctx, &masterapi.TaskRequest{Workerid: "ghost"})
+		if status.Code(err) == codes.FailedPrecondition {
+			// Declared dead before the jobs had tasks
+			_, err = server.RegisterWorker(ctx, &masterapi.RegisterWorkerRequest{
+				Workerid: "ghost", Workeraddress: "127.0.0.1", Workerport: closedPort(t),
+			})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ghostTask = task.GetTaskid()
+	}
+	submitted.Wait()
+
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		select {
+		case err := <-errs:
+			t.Fatal(err)
+		default:
+		}
+
+		resp, err := server.ListJobs(ctx, &masterapi.ListJobsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := 0
+		for _, job := range resp.GetJobs() {
+			switch job.GetPhase() {
+			case "done":
+				done++
+			case "failed", "cancelled":
+				t.Fatalf("job %s is %s: %s", job.GetJobid(), job.GetPhase(), job.GetFailurereason())
+			}
+		}
+		if len(resp.GetJobs()) == len(inputs) && done == len(inputs) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not finish: %v", resp.GetJobs())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, jobID := range jobIDs {
+		status, err := server.GetJobStatus(ctx, &masterapi.JobStatusRequest{Jobid: jobID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := status.GetReducetasks().GetDone(); got != 2 {
+			t.Errorf("job %s finished %d reduce tasks, want 2", jobID, got)
+		}
+	}
+
+	// Finished jobs are left out of the saved state, the workers are not
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state masterState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Jobs) != 0 || state.NextJobID != int64(len(inputs)) || len(state.Workers) != 6 {
+		t.Fatalf("saved state has %d jobs, next job ID %d and %d workers, want 0, %d and 6",
+			len(state.Jobs), state.NextJobID, len(state.Workers), len(inputs))
+	}
+	if tasks := state.WorkerTasks["ghost"]; len(tasks) != 0 {
+		t.Fatalf("dead worker still holds %v after task %s was re-queued", tasks, ghostTask)
+	}
+}
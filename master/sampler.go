@@ -0,0 +1,89 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"go-mr/storage"
+	"go-mr/types"
+)
+
+// DefaultSampleSize is the number of keys sampled to pick the reducer ranges
+// of a total order job.
+const DefaultSampleSize = 10000
+
+// sampleKeyRanges prepares the map tasks of a total order job. It runs the
+// plugin mapper over the first records of every split, picks
+// numberReducers-1 boundary keys at even quantiles of the sampled keys and
+// sends them to the mappers, which then range partition their output instead
+// of hashing it. Concatenating part-00000 to part-N gives one sorted dataset.
+// It must be called before the job is handed to the scheduler.
+func (m *MasterNode) sampleKeyRanges(job *Job, sampleSize int, format storage.InputFormat) error {
+	if sampleSize < job.numberReducers {
+		sampleSize = job.numberReducers
+	}
+
+	mapper, err := types.LoadMapper(job.pluginfilepath)
+	if err != nil {
+		return fmt.Errorf("failed to load mapper for sampling: %v", err)
+	}
+
+	perSplit := (sampleSize + len(job.pendingTasks) - 1) / len(job.pendingTasks)
+	var keys []string
+	for _, task := range job.pendingTasks {
+		sampled, err := m.sampleSplit(mapper, inputRange(task), format, perSplit)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, sampled...)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys found while sampling the input")
+	}
+	sort.Strings(keys)
+
+	boundaries := make([]string, 0, job.numberReducers-1)
+	for r := 1; r < job.numberReducers; r++ {
+		boundaries = append(boundaries, keys[r*len(keys)/job.numberReducers])
+	}
+	encoded, err := json.Marshal(boundaries)
+	if err != nil {
+		return fmt.Errorf("failed to encode range boundaries: %v", err)
+	}
+
+	for _, task := range job.pendingTasks {
+		task.Metadata["rangeBoundaries"] = string(encoded)
+	}
+	fmt.Printf("Sampled %d keys for job %s, reducer range boundaries: %s\n", len(keys), job.ID, encoded)
+	return nil
+}
+
+// sampleSplit returns up to limit keys emitted for the first records of a
+// split.
+func (m *MasterNode) sampleSplit(mapper types.Mapper, input storage.InputRange, format storage.InputFormat, limit int) ([]string, error) {
+	records, file, err := storage.OpenRecords(m.storage, input, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open split for sampling: %v", err)
+	}
+	defer file.Close()
+
+	var keys []string
+	for len(keys) < limit {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read split for sampling: %v", err)
+		}
+		for _, kv := range mapper(string(record)) {
+			if len(keys) == limit {
+				break
+			}
+			keys = append(keys, kv.Key)
+		}
+	}
+	return keys, nil
+}
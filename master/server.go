@@ -4,6 +4,12 @@ import (
 	"context"
 	"fmt"
 	"go-mr/masterapi"
+	"net"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type MasterApiServer struct {
@@ -20,6 +26,7 @@ func NewMasterApiServer(master *MasterNode) *MasterApiServer {
 func (ms *MasterApiServer) RegisterWorker(ctx context.Context, req *masterapi.RegisterWorkerRequest) (*masterapi.RegisterWorkerResponse, error) {
 	workerId := req.GetWorkerid()
 	workerPort := req.GetWorkerport()
+	workerAddress := req.GetWorkeraddress()
 
 	// Basic validation
 	if workerId == "" {
@@ -29,7 +36,16 @@ func (ms *MasterApiServer) RegisterWorker(ctx context.Context, req *masterapi.Re
 		return nil, fmt.Errorf("worker port cannot be empty")
 	}
 
-	ms.master.RegisterWorker(workerId, workerPort)
+	// Fall back to the address the request came from
+	if workerAddress == "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				workerAddress = host
+			}
+		}
+	}
+
+	ms.master.RegisterWorker(workerId, workerAddress, workerPort)
 	return &masterapi.RegisterWorkerResponse{}, nil
 }
 
@@ -41,7 +57,7 @@ func (ms *MasterApiServer) RequestTask(ctx context.Context, req *masterapi.TaskR
 		return nil, fmt.Errorf("worker ID cannot be empty")
 	}
 
-	replyChan := make(chan *TaskResponse)
+	replyChan := make(chan *TaskResponse, 1)
 	taskRequest := &TaskRequest{
 		WorkerID: workerId,
 		ReplyCh:  replyChan,
@@ -59,6 +75,9 @@ func (ms *MasterApiServer) RequestTask(ctx context.Context, req *masterapi.TaskR
 	select {
 	case taskResp, ok := <-replyChan:
 		if !ok {
+			if taskRequest.Refused {
+				return nil, status.Errorf(codes.FailedPrecondition, "worker %s is not registered or was declared dead, register again", workerId)
+			}
 			// No tasks available - channel was closed
 			return &masterapi.TaskResponse{
 				Taskid:    "",
@@ -73,7 +92,9 @@ func (ms *MasterApiServer) RequestTask(ctx context.Context, req *masterapi.TaskR
 			Taskid:    taskResp.TaskID,
 			Tasktype:  taskResp.TaskType,
 			Inputpath: taskResp.InputPath,
+			Outputdir: taskResp.OutputDir,
 			Metadata:  taskResp.Metadata,
+			Attempt:   int32(taskResp.Attempt),
 		}, nil
 
 	case <-ctx.Done():
@@ -100,9 +121,12 @@ func (ms *MasterApiServer) ReportTaskStatus(ctx context.Context, req *masterapi.
 	report := &TaskStatusReport{
 		WorkerID:          workerID,
 		TaskID:            taskID,
+		Attempt:           int(req.GetAttempt()),
 		Success:           success,
 		Error:             errorMsg,
 		IntermediateFiles: intermediateFiles,
+		CombinedRecords:   req.GetCombinedrecords(),
+		FetchFailedTask:   req.GetFetchfailedtask(),
 	}
 
 	// Send to master's taskSubmissionChannel for processing with context support
@@ -113,3 +137,91 @@ func (ms *MasterApiServer) ReportTaskStatus(ctx context.Context, req *masterapi.
 		return &masterapi.TaskStatusAck{Success: false}, ctx.Err()
 	}
 }
+
+func (ms *MasterApiServer) SubmitJob(ctx context.Context, req *masterapi.SubmitJobRequest) (*masterapi.SubmitJobResponse, error) {
+	spec := JobSpec{
+		Inputs:         req.GetInputs(),
+		PluginFile:     req.GetPlugin(),
+		NumberReducers: int(req.GetReducers()),
+		OutputDir:      req.GetOutputdir(),
+		TotalOrder:     req.GetTotalorder(),
+		SampleSize:     int(req.GetSamplesize()),
+		InputFormat:    req.GetFormat(),
+	}
+
+	jobID, err := ms.master.SubmitJob(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit job: %v", err)
+	}
+	return &masterapi.SubmitJobResponse{Jobid: jobID}, nil
+}
+
+func (ms *MasterApiServer) GetJobStatus(ctx context.Context, req *masterapi.JobStatusRequest) (*masterapi.JobStatus, error) {
+	if req.GetJobid() == "" {
+		return nil, fmt.Errorf("job ID cannot be empty")
+	}
+
+	status, err := ms.master.JobStatus(req.GetJobid())
+	if err != nil {
+		return nil, err
+	}
+	return jobStatusToProto(status), nil
+}
+
+func (ms *MasterApiServer) CancelJob(ctx context.Context, req *masterapi.CancelJobRequest) (*masterapi.CancelJobResponse, error) {
+	if req.GetJobid() == "" {
+		return nil, fmt.Errorf("job ID cannot be empty")
+	}
+
+	status, err := ms.master.CancelJob(req.GetJobid())
+	if err != nil {
+		return nil, err
+	}
+	return &masterapi.CancelJobResponse{Phase: status.Phase.String()}, nil
+}
+
+func (ms *MasterApiServer) ListJobs(ctx context.Context, req *masterapi.ListJobsRequest) (*masterapi.ListJobsResponse, error) {
+	statuses := ms.master.ListJobs()
+
+	jobs := make([]*masterapi.JobStatus, 0, len(statuses))
+	for _, status := range statuses {
+		jobs = append(jobs, jobStatusToProto(status))
+	}
+	return &masterapi.ListJobsResponse{Jobs: jobs}, nil
+}
+
+func jobStatusToProto(status JobStatus) *masterapi.JobStatus {
+	workerIDs := make([]string, 0, len(status.Assignments))
+	for workerID := range status.Assignments {
+		workerIDs = append(workerIDs, workerID)
+	}
+	sort.Strings(workerIDs)
+
+	assignments := make([]*masterapi.WorkerAssignment, 0, len(workerIDs))
+	for _, workerID := range workerIDs {
+		assignments = append(assignments, &masterapi.WorkerAssignment{
+			Workerid: workerID,
+			Taskids:  status.Assignments[workerID],
+		})
+	}
+
+	return &masterapi.JobStatus{
+		Jobid:         status.JobID,
+		Phase:         status.Phase.String(),
+		Inputs:        status.Inputs,
+		Outputdir:     status.OutputDir,
+		Maptasks:      taskCountsToProto(status.MapTasks),
+		Reducetasks:   taskCountsToProto(status.ReduceTasks),
+		Assignments:   assignments,
+		Failurereason: status.FailureReason,
+	}
+}
+
+func taskCountsToProto(counts TaskCounts) *masterapi.TaskCounts {
+	return &masterapi.TaskCounts{
+		Pending: int32(counts.Pending),
+		Active:  int32(counts.Active),
+		Done:    int32(counts.Done),
+		Failed:  int32(counts.Failed),
+	}
+}
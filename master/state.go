@@ -0,0 +1,246 @@
+package master
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go-mr/storage"
+)
+
+// stateVersion is bumped whenever the layout of the state file changes.
+const stateVersion = 1
+
+// masterState is the snapshot of scheduler state the master writes to its
+// state file, so a restarted master can resume its jobs.
+type masterState struct {
+	Version     int                 `json:"version"`
+	NextJobID   int64               `json:"next_job_id"`
+	Workers     []persistedWorker   `json:"workers"`
+	WorkerTasks map[string][]string `json:"worker_tasks"`
+	Jobs        []persistedJob      `json:"jobs"`
+}
+
+type persistedWorker struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Port    string `json:"port"`
+}
+
+type persistedJob struct {
+	ID                       string                       `json:"id"`
+	Inputs                   []string                     `json:"inputs"`
+	NumberReducers           int                          `json:"number_reducers"`
+	PluginFile               string                       `json:"plugin_file"`
+	OutputDir                string                       `json:"output_dir"`
+	Phase                    ExecutionPhase               `json:"phase"`
+	FailureReason            string                       `json:"failure_reason,omitempty"`
+	Tasks                    []*TaskResponse              `json:"tasks"`
+	PendingTasks             []string                     `json:"pending_tasks"`
+	ActiveTasks              map[string]taskLease         `json:"active_tasks"`
+	ReducerIntermediateFiles map[string][]string          `json:"reducer_intermediate_files"`
+	CompletedBy              map[string]string            `json:"completed_by"`
+	MapOutputs               map[string]map[string]string `json:"map_outputs"`
+	CombinedRecords          map[string]int64             `json:"combined_records"`
+	FailedAttempts           map[string]int               `json:"failed_attempts"`
+	UncountedAttempts        map[string]int               `json:"uncounted_attempts,omitempty"`
+	SplitDirs                []string                     `json:"split_dirs,omitempty"`
+}
+
+// SetStatePath sets the file the scheduler state is saved to after every
+// change. Only unfinished jobs are saved, so a restarted master forgets jobs
+// that were done, failed or cancelled. An empty path disables persistence.
+// It must be called before LoadState and StartScheduler.
+func (m *MasterNode) SetStatePath(path string) {
+	m.statePath = path
+}
+
+// LoadState restores the jobs, task queues and workers saved in the state
+// file by an earlier master. Completed tasks and their map outputs are kept.
+// Tasks that were running get a fresh lease, so workers still executing them
+// can report back; if they don't, the lease expires and the task is retried
+// as usual. It returns the number of restored jobs that still have work
+// left and must be called before StartScheduler.
+func (m *MasterNode) LoadState() (int, error) {
+	if m.statePath == "" {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(m.statePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read state file: %v", err)
+	}
+
+	var state masterState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("failed to parse state file: %v", err)
+	}
+	if state.Version != stateVersion {
+		return 0, fmt.Errorf("unsupported state file version %d", state.Version)
+	}
+
+	m.nextJobID.Store(state.NextJobID)
+
+	m.workersMu.Lock()
+	for _, w := range state.Workers {
+		m.workers[w.ID] = &WorkerInfo{ID: w.ID, Address: w.Address, Port: w.Port, Active: true}
+	}
+	m.workersMu.Unlock()
+
+	if state.WorkerTasks != nil {
+		m.workerIdTaskMap = state.WorkerTasks
+	}
+
+	deadline := time.Now().Add(m.taskTimeout)
+	running := 0
+	for _, pj := range state.Jobs {
+		job := newJob(pj.ID, JobSpec{
+			Inputs:         pj.Inputs,
+			PluginFile:     pj.PluginFile,
+			NumberReducers: pj.NumberReducers,
+			OutputDir:      pj.OutputDir,
+		})
+		job.phase = pj.Phase
+		job.failureReason = pj.FailureReason
+		if job.phase == PhaseSplitting {
+			// The split ran on the previous master and died with it
+			job.phase = PhaseFailed
+			job.failureReason = "master restarted while the inputs were being split"
+			m.stateChanged.Store(true)
+		}
+
+		for _, task := range pj.Tasks {
+			m.tasks[task.TaskID] = task
+		}
+		for _, taskID := range pj.PendingTasks {
+			task, ok := m.tasks[taskID]
+			if !ok {
+				return 0, fmt.Errorf("state file queues unknown task %s", taskID)
+			}
+			job.pendingTasks = append(job.pendingTasks, task)
+		}
+		for taskID, lease := range pj.ActiveTasks {
+			lease.Deadline = deadline
+			job.activeTasks[taskID] = &lease
+		}
+		copyMap(job.reducerIntermediateFiles, pj.ReducerIntermediateFiles)
+		copyMap(job.completedBy, pj.CompletedBy)
+		copyMap(job.mapOutputs, pj.MapOutputs)
+		copyMap(job.combinedRecords, pj.CombinedRecords)
+		copyMap(job.failedAttempts, pj.FailedAttempts)
+		copyMap(job.uncountedAttempts, pj.UncountedAttempts)
+		if !job.finished() {
+			job.splitDirs = pj.SplitDirs
+			m.splitter.Acquire(job.splitDirs...)
+		}
+
+		m.jobs[job.ID] = job
+		m.jobOrder = append(m.jobOrder, job)
+		if !job.running() {
+			continue
+		}
+		running++
+		fmt.Printf("Resumed job %s in %s phase: %d tasks pending, %d running, %d completed\n",
+			job.ID, job.phase, len(job.pendingTasks), len(job.activeTasks), len(job.completedBy))
+	}
+
+	m.savedState = data
+	return running, nil
+}
+
+func copyMap[V any](dst, src map[string]V) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// saveState writes the scheduler state to the state file if it changed
+// since the last save. It runs on the scheduling loop. The file is replaced
+// atomically, so a crash while saving leaves the previous snapshot intact.
+func (m *MasterNode) saveState() {
+	if m.statePath == "" || !m.stateChanged.Swap(false) {
+		return
+	}
+
+	data, err := json.MarshalIndent(m.snapshotState(), "", "  ")
+	if err != nil {
+		fmt.Printf("Failed to encode master state: %v\n", err)
+		return
+	}
+	if bytes.Equal(data, m.savedState) {
+		return
+	}
+
+	if err := storage.WriteFileAtomic(m.statePath, data); err != nil {
+		fmt.Printf("Failed to save master state: %v\n", err)
+		m.stateChanged.Store(true)
+		return
+	}
+	m.savedState = data
+}
+
+// snapshotState collects the state of every unfinished job and registered
+// worker.
+func (m *MasterNode) snapshotState() *masterState {
+	state := &masterState{
+		Version:     stateVersion,
+		NextJobID:   m.nextJobID.Load(),
+		WorkerTasks: m.workerIdTaskMap,
+	}
+
+	m.workersMu.Lock()
+	for _, w := range m.workers {
+		state.Workers = append(state.Workers, persistedWorker{ID: w.ID, Address: w.Address, Port: w.Port})
+	}
+	m.workersMu.Unlock()
+	sort.Slice(state.Workers, func(i, j int) bool { return state.Workers[i].ID < state.Workers[j].ID })
+
+	tasksByJob := make(map[string][]*TaskResponse)
+	for _, task := range m.tasks {
+		tasksByJob[task.JobID] = append(tasksByJob[task.JobID], task)
+	}
+
+	for _, job := range m.jobOrder {
+		if job.finished() {
+			continue
+		}
+		tasks := tasksByJob[job.ID]
+		sort.Slice(tasks, func(i, j int) bool { return tasks[i].TaskID < tasks[j].TaskID })
+
+		pending := make([]string, 0, len(job.pendingTasks))
+		for _, task := range job.pendingTasks {
+			pending = append(pending, task.TaskID)
+		}
+		active := make(map[string]taskLease, len(job.activeTasks))
+		for taskID, lease := range job.activeTasks {
+			active[taskID] = taskLease{WorkerID: lease.WorkerID, Attempt: lease.Attempt}
+		}
+
+		state.Jobs = append(state.Jobs, persistedJob{
+			ID:                       job.ID,
+			Inputs:                   job.inputs,
+			NumberReducers:           job.numberReducers,
+			PluginFile:               job.pluginfilepath,
+			OutputDir:                job.outputfilepath,
+			Phase:                    job.phase,
+			FailureReason:            job.failureReason,
+			Tasks:                    tasks,
+			PendingTasks:             pending,
+			ActiveTasks:              active,
+			ReducerIntermediateFiles: job.reducerIntermediateFiles,
+			CompletedBy:              job.completedBy,
+			MapOutputs:               job.mapOutputs,
+			CombinedRecords:          job.combinedRecords,
+			FailedAttempts:           job.failedAttempts,
+			UncountedAttempts:        job.uncountedAttempts,
+			SplitDirs:                job.splitDirs,
+		})
+	}
+	return state
+}
@@ -0,0 +1,160 @@
+package master
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TaskCounts counts the tasks of one type in a job. Failed counts tasks with
+// at least one failed or timed out attempt, whether or not a retry succeeded.
+type TaskCounts struct {
+	Pending int
+	Active  int
+	Done    int
+	Failed  int
+}
+
+// JobStatus is a snapshot of a job taken by the scheduling loop.
+type JobStatus struct {
+	JobID         string
+	Phase         ExecutionPhase
+	Inputs        []string
+	OutputDir     string
+	MapTasks      TaskCounts
+	ReduceTasks   TaskCounts
+	Assignments   map[string][]string // workerID -> taskIDs it is running
+	FailureReason string
+}
+
+// jobQuery asks the scheduling loop for job statuses or to cancel a job. An
+// empty JobID selects every job.
+type jobQuery struct {
+	JobID   string
+	Cancel  bool
+	ReplyCh chan jobQueryResult
+}
+
+type jobQueryResult struct {
+	Statuses []JobStatus
+	Err      error
+}
+
+// JobStatus returns the status of one job.
+func (m *MasterNode) JobStatus(jobID string) (JobStatus, error) {
+	result := m.queryJobs(&jobQuery{JobID: jobID})
+	if result.Err != nil {
+		return JobStatus{}, result.Err
+	}
+	return result.Statuses[0], nil
+}
+
+// ListJobs returns the status of every job in submission order.
+func (m *MasterNode) ListJobs() []JobStatus {
+	return m.queryJobs(&jobQuery{}).Statuses
+}
+
+// CancelJob stops handing out tasks of a job and returns its status. Tasks
+// already running finish on their workers, but their reports are rejected.
+// Cancelling a job that already finished leaves it as it is.
+func (m *MasterNode) CancelJob(jobID string) (JobStatus, error) {
+	result := m.queryJobs(&jobQuery{JobID: jobID, Cancel: true})
+	if result.Err != nil {
+		return JobStatus{}, result.Err
+	}
+	return result.Statuses[0], nil
+}
+
+func (m *MasterNode) queryJobs(query *jobQuery) jobQueryResult {
+	query.ReplyCh = make(chan jobQueryResult, 1)
+	m.jobQueryChannel <- query
+	return <-query.ReplyCh
+}
+
+// answerJobQuery runs a job query on the scheduling loop.
+func (m *MasterNode) answerJobQuery(query *jobQuery) {
+	if query.JobID == "" {
+		statuses := make([]JobStatus, 0, len(m.jobOrder))
+		for _, job := range m.jobOrder {
+			statuses = append(statuses, m.jobStatus(job))
+		}
+		query.ReplyCh <- jobQueryResult{Statuses: statuses}
+		return
+	}
+
+	job, ok := m.jobs[query.JobID]
+	if !ok {
+		query.ReplyCh <- jobQueryResult{Err: fmt.Errorf("unknown job %s", query.JobID)}
+		return
+	}
+	if query.Cancel {
+		m.cancelJob(job)
+	}
+	query.ReplyCh <- jobQueryResult{Statuses: []JobStatus{m.jobStatus(job)}}
+}
+
+// cancelJob drops the queued tasks and leases of a job that hasn't finished.
+func (m *MasterNode) cancelJob(job *Job) {
+	if job.finished() {
+		return
+	}
+	job.phase = PhaseCancelled
+	job.pendingTasks = nil
+	clear(job.activeTasks)
+	m.retireJob(job)
+	m.stateChanged.Store(true)
+	fmt.Printf("[✗] Job %s cancelled\n", job.ID)
+}
+
+// jobStatus counts the tasks of a job by type and state. A finished job
+// reports the status it had when it finished.
+func (m *MasterNode) jobStatus(job *Job) JobStatus {
+	if job.finalStatus != nil {
+		return job.finalStatus.clone()
+	}
+
+	status := JobStatus{
+		JobID:         job.ID,
+		Phase:         job.phase,
+		Inputs:        append([]string(nil), job.inputs...),
+		OutputDir:     job.outputfilepath,
+		Assignments:   make(map[string][]string),
+		FailureReason: job.failureReason,
+	}
+
+	counts := func(taskID string) *TaskCounts {
+		if task, ok := m.tasks[taskID]; ok && task.TaskType == "reduce" {
+			return &status.ReduceTasks
+		}
+		return &status.MapTasks
+	}
+
+	for _, task := range job.pendingTasks {
+		counts(task.TaskID).Pending++
+	}
+	for taskID, lease := range job.activeTasks {
+		counts(taskID).Active++
+		status.Assignments[lease.WorkerID] = append(status.Assignments[lease.WorkerID], taskID)
+	}
+	for taskID := range job.completedBy {
+		counts(taskID).Done++
+	}
+	for taskID := range job.failedAttempts {
+		counts(taskID).Failed++
+	}
+
+	for _, taskIDs := range status.Assignments {
+		sort.Strings(taskIDs)
+	}
+	return status
+}
+
+// clone returns a copy of the status that shares no slices or maps with it.
+func (s JobStatus) clone() JobStatus {
+	s.Inputs = append([]string(nil), s.Inputs...)
+	assignments := make(map[string][]string, len(s.Assignments))
+	for workerID, taskIDs := range s.Assignments {
+		assignments[workerID] = append([]string(nil), taskIDs...)
+	}
+	s.Assignments = assignments
+	return s
+}
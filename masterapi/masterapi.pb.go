@@ -25,6 +25,7 @@ type RegisterWorkerRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Workerid      string                 `protobuf:"bytes,1,opt,name=workerid,proto3" json:"workerid,omitempty"`
 	Workerport    string                 `protobuf:"bytes,2,opt,name=workerport,proto3" json:"workerport,omitempty"`
+	Workeraddress string                 `protobuf:"bytes,3,opt,name=workeraddress,proto3" json:"workeraddress,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -73,6 +74,13 @@ func (x *RegisterWorkerRequest) GetWorkerport() string {
 	return ""
 }
 
+func (x *RegisterWorkerRequest) GetWorkeraddress() string {
+	if x != nil {
+		return x.Workeraddress
+	}
+	return ""
+}
+
 type RegisterWorkerResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -176,6 +184,7 @@ type TaskResponse struct {
 	Inputpath     string                 `protobuf:"bytes,3,opt,name=inputpath,proto3" json:"inputpath,omitempty"`
 	Outputdir     string                 `protobuf:"bytes,4,opt,name=outputdir,proto3" json:"outputdir,omitempty"`
 	Metadata      map[string]string      `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Attempt       int32                  `protobuf:"varint,6,opt,name=attempt,proto3" json:"attempt,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -245,6 +254,13 @@ func (x *TaskResponse) GetMetadata() map[string]string {
 	return nil
 }
 
+func (x *TaskResponse) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
 type TaskStatusReport struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	Workerid          string                 `protobuf:"bytes,1,opt,name=workerid,proto3" json:"workerid,omitempty"`
@@ -252,6 +268,9 @@ type TaskStatusReport struct {
 	Success           bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
 	Error             string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
 	Intermediatefiles map[string]string      `protobuf:"bytes,5,rep,name=intermediatefiles,proto3" json:"intermediatefiles,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Attempt           int32                  `protobuf:"varint,6,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	Combinedrecords   int64                  `protobuf:"varint,7,opt,name=combinedrecords,proto3" json:"combinedrecords,omitempty"`
+	Fetchfailedtask   string                 `protobuf:"bytes,8,opt,name=fetchfailedtask,proto3" json:"fetchfailedtask,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -321,6 +340,27 @@ func (x *TaskStatusReport) GetIntermediatefiles() map[string]string {
 	return nil
 }
 
+func (x *TaskStatusReport) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
+func (x *TaskStatusReport) GetCombinedrecords() int64 {
+	if x != nil {
+		return x.Combinedrecords
+	}
+	return 0
+}
+
+func (x *TaskStatusReport) GetFetchfailedtask() string {
+	if x != nil {
+		return x.Fetchfailedtask
+	}
+	return ""
+}
+
 type TaskStatusAck struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
@@ -365,45 +405,665 @@ func (x *TaskStatusAck) GetSuccess() bool {
 	return false
 }
 
+type SubmitJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inputs        []string               `protobuf:"bytes,1,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Plugin        string                 `protobuf:"bytes,2,opt,name=plugin,proto3" json:"plugin,omitempty"`
+	Reducers      int32                  `protobuf:"varint,3,opt,name=reducers,proto3" json:"reducers,omitempty"`
+	Outputdir     string                 `protobuf:"bytes,4,opt,name=outputdir,proto3" json:"outputdir,omitempty"`
+	Totalorder    bool                   `protobuf:"varint,5,opt,name=totalorder,proto3" json:"totalorder,omitempty"`
+	Samplesize    int32                  `protobuf:"varint,6,opt,name=samplesize,proto3" json:"samplesize,omitempty"`
+	Format        string                 `protobuf:"bytes,7,opt,name=format,proto3" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobRequest) Reset() {
+	*x = SubmitJobRequest{}
+	mi := &file_masterapi_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobRequest) ProtoMessage() {}
+
+func (x *SubmitJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobRequest.ProtoReflect.Descriptor instead.
+func (*SubmitJobRequest) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SubmitJobRequest) GetInputs() []string {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *SubmitJobRequest) GetPlugin() string {
+	if x != nil {
+		return x.Plugin
+	}
+	return ""
+}
+
+func (x *SubmitJobRequest) GetReducers() int32 {
+	if x != nil {
+		return x.Reducers
+	}
+	return 0
+}
+
+func (x *SubmitJobRequest) GetOutputdir() string {
+	if x != nil {
+		return x.Outputdir
+	}
+	return ""
+}
+
+func (x *SubmitJobRequest) GetTotalorder() bool {
+	if x != nil {
+		return x.Totalorder
+	}
+	return false
+}
+
+func (x *SubmitJobRequest) GetSamplesize() int32 {
+	if x != nil {
+		return x.Samplesize
+	}
+	return 0
+}
+
+func (x *SubmitJobRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type SubmitJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobid         string                 `protobuf:"bytes,1,opt,name=jobid,proto3" json:"jobid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobResponse) Reset() {
+	*x = SubmitJobResponse{}
+	mi := &file_masterapi_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobResponse) ProtoMessage() {}
+
+func (x *SubmitJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobResponse.ProtoReflect.Descriptor instead.
+func (*SubmitJobResponse) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SubmitJobResponse) GetJobid() string {
+	if x != nil {
+		return x.Jobid
+	}
+	return ""
+}
+
+type JobStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobid         string                 `protobuf:"bytes,1,opt,name=jobid,proto3" json:"jobid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobStatusRequest) Reset() {
+	*x = JobStatusRequest{}
+	mi := &file_masterapi_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobStatusRequest) ProtoMessage() {}
+
+func (x *JobStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobStatusRequest.ProtoReflect.Descriptor instead.
+func (*JobStatusRequest) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *JobStatusRequest) GetJobid() string {
+	if x != nil {
+		return x.Jobid
+	}
+	return ""
+}
+
+type TaskCounts struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pending       int32                  `protobuf:"varint,1,opt,name=pending,proto3" json:"pending,omitempty"`
+	Active        int32                  `protobuf:"varint,2,opt,name=active,proto3" json:"active,omitempty"`
+	Done          int32                  `protobuf:"varint,3,opt,name=done,proto3" json:"done,omitempty"`
+	Failed        int32                  `protobuf:"varint,4,opt,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskCounts) Reset() {
+	*x = TaskCounts{}
+	mi := &file_masterapi_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskCounts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskCounts) ProtoMessage() {}
+
+func (x *TaskCounts) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskCounts.ProtoReflect.Descriptor instead.
+func (*TaskCounts) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TaskCounts) GetPending() int32 {
+	if x != nil {
+		return x.Pending
+	}
+	return 0
+}
+
+func (x *TaskCounts) GetActive() int32 {
+	if x != nil {
+		return x.Active
+	}
+	return 0
+}
+
+func (x *TaskCounts) GetDone() int32 {
+	if x != nil {
+		return x.Done
+	}
+	return 0
+}
+
+func (x *TaskCounts) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+type WorkerAssignment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Workerid      string                 `protobuf:"bytes,1,opt,name=workerid,proto3" json:"workerid,omitempty"`
+	Taskids       []string               `protobuf:"bytes,2,rep,name=taskids,proto3" json:"taskids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerAssignment) Reset() {
+	*x = WorkerAssignment{}
+	mi := &file_masterapi_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerAssignment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerAssignment) ProtoMessage() {}
+
+func (x *WorkerAssignment) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerAssignment.ProtoReflect.Descriptor instead.
+func (*WorkerAssignment) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WorkerAssignment) GetWorkerid() string {
+	if x != nil {
+		return x.Workerid
+	}
+	return ""
+}
+
+func (x *WorkerAssignment) GetTaskids() []string {
+	if x != nil {
+		return x.Taskids
+	}
+	return nil
+}
+
+type JobStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobid         string                 `protobuf:"bytes,1,opt,name=jobid,proto3" json:"jobid,omitempty"`
+	Phase         string                 `protobuf:"bytes,2,opt,name=phase,proto3" json:"phase,omitempty"`
+	Inputs        []string               `protobuf:"bytes,3,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Outputdir     string                 `protobuf:"bytes,4,opt,name=outputdir,proto3" json:"outputdir,omitempty"`
+	Maptasks      *TaskCounts            `protobuf:"bytes,5,opt,name=maptasks,proto3" json:"maptasks,omitempty"`
+	Reducetasks   *TaskCounts            `protobuf:"bytes,6,opt,name=reducetasks,proto3" json:"reducetasks,omitempty"`
+	Assignments   []*WorkerAssignment    `protobuf:"bytes,7,rep,name=assignments,proto3" json:"assignments,omitempty"`
+	Failurereason string                 `protobuf:"bytes,8,opt,name=failurereason,proto3" json:"failurereason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobStatus) Reset() {
+	*x = JobStatus{}
+	mi := &file_masterapi_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobStatus) ProtoMessage() {}
+
+func (x *JobStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobStatus.ProtoReflect.Descriptor instead.
+func (*JobStatus) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *JobStatus) GetJobid() string {
+	if x != nil {
+		return x.Jobid
+	}
+	return ""
+}
+
+func (x *JobStatus) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+func (x *JobStatus) GetInputs() []string {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *JobStatus) GetOutputdir() string {
+	if x != nil {
+		return x.Outputdir
+	}
+	return ""
+}
+
+func (x *JobStatus) GetMaptasks() *TaskCounts {
+	if x != nil {
+		return x.Maptasks
+	}
+	return nil
+}
+
+func (x *JobStatus) GetReducetasks() *TaskCounts {
+	if x != nil {
+		return x.Reducetasks
+	}
+	return nil
+}
+
+func (x *JobStatus) GetAssignments() []*WorkerAssignment {
+	if x != nil {
+		return x.Assignments
+	}
+	return nil
+}
+
+func (x *JobStatus) GetFailurereason() string {
+	if x != nil {
+		return x.Failurereason
+	}
+	return ""
+}
+
+type CancelJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobid         string                 `protobuf:"bytes,1,opt,name=jobid,proto3" json:"jobid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelJobRequest) Reset() {
+	*x = CancelJobRequest{}
+	mi := &file_masterapi_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelJobRequest) ProtoMessage() {}
+
+func (x *CancelJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelJobRequest.ProtoReflect.Descriptor instead.
+func (*CancelJobRequest) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CancelJobRequest) GetJobid() string {
+	if x != nil {
+		return x.Jobid
+	}
+	return ""
+}
+
+type CancelJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Phase         string                 `protobuf:"bytes,1,opt,name=phase,proto3" json:"phase,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelJobResponse) Reset() {
+	*x = CancelJobResponse{}
+	mi := &file_masterapi_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelJobResponse) ProtoMessage() {}
+
+func (x *CancelJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelJobResponse.ProtoReflect.Descriptor instead.
+func (*CancelJobResponse) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CancelJobResponse) GetPhase() string {
+	if x != nil {
+		return x.Phase
+	}
+	return ""
+}
+
+type ListJobsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsRequest) Reset() {
+	*x = ListJobsRequest{}
+	mi := &file_masterapi_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsRequest) ProtoMessage() {}
+
+func (x *ListJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{14}
+}
+
+type ListJobsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*JobStatus           `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsResponse) Reset() {
+	*x = ListJobsResponse{}
+	mi := &file_masterapi_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsResponse) ProtoMessage() {}
+
+func (x *ListJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_masterapi_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return file_masterapi_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListJobsResponse) GetJobs() []*JobStatus {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
 var File_masterapi_proto protoreflect.FileDescriptor
 
 const file_masterapi_proto_rawDesc = "" +
 	"\n" +
-	"\x0fmasterapi.proto\"S\n" +
+	"\x0fmasterapi.proto\"y\n" +
 	"\x15RegisterWorkerRequest\x12\x1a\n" +
 	"\bworkerid\x18\x01 \x01(\tR\bworkerid\x12\x1e\n" +
 	"\n" +
 	"workerport\x18\x02 \x01(\tR\n" +
-	"workerport\"L\n" +
+	"workerport\x12$\n" +
+	"\rworkeraddress\x18\x03 \x01(\tR\rworkeraddress\"L\n" +
 	"\x16RegisterWorkerResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\")\n" +
 	"\vTaskRequest\x12\x1a\n" +
-	"\bworkerid\x18\x01 \x01(\tR\bworkerid\"\xf4\x01\n" +
+	"\bworkerid\x18\x01 \x01(\tR\bworkerid\"\x8e\x02\n" +
 	"\fTaskResponse\x12\x16\n" +
 	"\x06taskid\x18\x01 \x01(\tR\x06taskid\x12\x1a\n" +
 	"\btasktype\x18\x02 \x01(\tR\btasktype\x12\x1c\n" +
 	"\tinputpath\x18\x03 \x01(\tR\tinputpath\x12\x1c\n" +
 	"\toutputdir\x18\x04 \x01(\tR\toutputdir\x127\n" +
-	"\bmetadata\x18\x05 \x03(\v2\x1b.TaskResponse.MetadataEntryR\bmetadata\x1a;\n" +
+	"\bmetadata\x18\x05 \x03(\v2\x1b.TaskResponse.MetadataEntryR\bmetadata\x12\x18\n" +
+	"\aattempt\x18\x06 \x01(\x05R\aattempt\x1a;\n" +
 	"\rMetadataEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x94\x02\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x82\x03\n" +
 	"\x10TaskStatusReport\x12\x1a\n" +
 	"\bworkerid\x18\x01 \x01(\tR\bworkerid\x12\x16\n" +
 	"\x06taskid\x18\x02 \x01(\tR\x06taskid\x12\x18\n" +
 	"\asuccess\x18\x03 \x01(\bR\asuccess\x12\x14\n" +
 	"\x05error\x18\x04 \x01(\tR\x05error\x12V\n" +
-	"\x11intermediatefiles\x18\x05 \x03(\v2(.TaskStatusReport.IntermediatefilesEntryR\x11intermediatefiles\x1aD\n" +
+	"\x11intermediatefiles\x18\x05 \x03(\v2(.TaskStatusReport.IntermediatefilesEntryR\x11intermediatefiles\x12\x18\n" +
+	"\aattempt\x18\x06 \x01(\x05R\aattempt\x12(\n" +
+	"\x0fcombinedrecords\x18\a \x01(\x03R\x0fcombinedrecords\x12(\n" +
+	"\x0ffetchfailedtask\x18\b \x01(\tR\x0ffetchfailedtask\x1aD\n" +
 	"\x16IntermediatefilesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\")\n" +
 	"\rTaskStatusAck\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2\xb1\x01\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"\xd4\x01\n" +
+	"\x10SubmitJobRequest\x12\x16\n" +
+	"\x06inputs\x18\x01 \x03(\tR\x06inputs\x12\x16\n" +
+	"\x06plugin\x18\x02 \x01(\tR\x06plugin\x12\x1a\n" +
+	"\breducers\x18\x03 \x01(\x05R\breducers\x12\x1c\n" +
+	"\toutputdir\x18\x04 \x01(\tR\toutputdir\x12\x1e\n" +
+	"\n" +
+	"totalorder\x18\x05 \x01(\bR\n" +
+	"totalorder\x12\x1e\n" +
+	"\n" +
+	"samplesize\x18\x06 \x01(\x05R\n" +
+	"samplesize\x12\x16\n" +
+	"\x06format\x18\a \x01(\tR\x06format\")\n" +
+	"\x11SubmitJobResponse\x12\x14\n" +
+	"\x05jobid\x18\x01 \x01(\tR\x05jobid\"(\n" +
+	"\x10JobStatusRequest\x12\x14\n" +
+	"\x05jobid\x18\x01 \x01(\tR\x05jobid\"j\n" +
+	"\n" +
+	"TaskCounts\x12\x18\n" +
+	"\apending\x18\x01 \x01(\x05R\apending\x12\x16\n" +
+	"\x06active\x18\x02 \x01(\x05R\x06active\x12\x12\n" +
+	"\x04done\x18\x03 \x01(\x05R\x04done\x12\x16\n" +
+	"\x06failed\x18\x04 \x01(\x05R\x06failed\"H\n" +
+	"\x10WorkerAssignment\x12\x1a\n" +
+	"\bworkerid\x18\x01 \x01(\tR\bworkerid\x12\x18\n" +
+	"\ataskids\x18\x02 \x03(\tR\ataskids\"\xa0\x02\n" +
+	"\tJobStatus\x12\x14\n" +
+	"\x05jobid\x18\x01 \x01(\tR\x05jobid\x12\x14\n" +
+	"\x05phase\x18\x02 \x01(\tR\x05phase\x12\x16\n" +
+	"\x06inputs\x18\x03 \x03(\tR\x06inputs\x12\x1c\n" +
+	"\toutputdir\x18\x04 \x01(\tR\toutputdir\x12'\n" +
+	"\bmaptasks\x18\x05 \x01(\v2\v.TaskCountsR\bmaptasks\x12-\n" +
+	"\vreducetasks\x18\x06 \x01(\v2\v.TaskCountsR\vreducetasks\x123\n" +
+	"\vassignments\x18\a \x03(\v2\x11.WorkerAssignmentR\vassignments\x12$\n" +
+	"\rfailurereason\x18\b \x01(\tR\rfailurereason\"(\n" +
+	"\x10CancelJobRequest\x12\x14\n" +
+	"\x05jobid\x18\x01 \x01(\tR\x05jobid\")\n" +
+	"\x11CancelJobResponse\x12\x14\n" +
+	"\x05phase\x18\x01 \x01(\tR\x05phase\"\x11\n" +
+	"\x0fListJobsRequest\"2\n" +
+	"\x10ListJobsResponse\x12\x1e\n" +
+	"\x04jobs\x18\x01 \x03(\v2\n" +
+	".JobStatusR\x04jobs2\xf9\x02\n" +
 	"\tMasterApi\x12A\n" +
 	"\x0eRegisterWorker\x12\x16.RegisterWorkerRequest\x1a\x17.RegisterWorkerResponse\x12*\n" +
 	"\vRequestTask\x12\f.TaskRequest\x1a\r.TaskResponse\x125\n" +
-	"\x10ReportTaskStatus\x12\x11.TaskStatusReport\x1a\x0e.TaskStatusAckB\x0eZ\f./;masterapib\x06proto3"
+	"\x10ReportTaskStatus\x12\x11.TaskStatusReport\x1a\x0e.TaskStatusAck\x122\n" +
+	"\tSubmitJob\x12\x11.SubmitJobRequest\x1a\x12.SubmitJobResponse\x12-\n" +
+	"\fGetJobStatus\x12\x11.JobStatusRequest\x1a\n" +
+	".JobStatus\x122\n" +
+	"\tCancelJob\x12\x11.CancelJobRequest\x1a\x12.CancelJobResponse\x12/\n" +
+	"\bListJobs\x12\x10.ListJobsRequest\x1a\x11.ListJobsResponseB\x0eZ\f./;masterapib\x06proto3"
 
 var (
 	file_masterapi_proto_rawDescOnce sync.Once
@@ -417,7 +1077,7 @@ func file_masterapi_proto_rawDescGZIP() []byte {
 	return file_masterapi_proto_rawDescData
 }
 
-var file_masterapi_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_masterapi_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_masterapi_proto_goTypes = []any{
 	(*RegisterWorkerRequest)(nil),  // 0: RegisterWorkerRequest
 	(*RegisterWorkerResponse)(nil), // 1: RegisterWorkerResponse
@@ -425,23 +1085,45 @@ var file_masterapi_proto_goTypes = []any{
 	(*TaskResponse)(nil),           // 3: TaskResponse
 	(*TaskStatusReport)(nil),       // 4: TaskStatusReport
 	(*TaskStatusAck)(nil),          // 5: TaskStatusAck
-	nil,                            // 6: TaskResponse.MetadataEntry
-	nil,                            // 7: TaskStatusReport.IntermediatefilesEntry
+	(*SubmitJobRequest)(nil),       // 6: SubmitJobRequest
+	(*SubmitJobResponse)(nil),      // 7: SubmitJobResponse
+	(*JobStatusRequest)(nil),       // 8: JobStatusRequest
+	(*TaskCounts)(nil),             // 9: TaskCounts
+	(*WorkerAssignment)(nil),       // 10: WorkerAssignment
+	(*JobStatus)(nil),              // 11: JobStatus
+	(*CancelJobRequest)(nil),       // 12: CancelJobRequest
+	(*CancelJobResponse)(nil),      // 13: CancelJobResponse
+	(*ListJobsRequest)(nil),        // 14: ListJobsRequest
+	(*ListJobsResponse)(nil),       // 15: ListJobsResponse
+	nil,                            // 16: TaskResponse.MetadataEntry
+	nil,                            // 17: TaskStatusReport.IntermediatefilesEntry
 }
 var file_masterapi_proto_depIdxs = []int32{
-	6, // 0: TaskResponse.metadata:type_name -> TaskResponse.MetadataEntry
-	7, // 1: TaskStatusReport.intermediatefiles:type_name -> TaskStatusReport.IntermediatefilesEntry
-	0, // 2: MasterApi.RegisterWorker:input_type -> RegisterWorkerRequest
-	2, // 3: MasterApi.RequestTask:input_type -> TaskRequest
-	4, // 4: MasterApi.ReportTaskStatus:input_type -> TaskStatusReport
-	1, // 5: MasterApi.RegisterWorker:output_type -> RegisterWorkerResponse
-	3, // 6: MasterApi.RequestTask:output_type -> TaskResponse
-	5, // 7: MasterApi.ReportTaskStatus:output_type -> TaskStatusAck
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	16, // 0: TaskResponse.metadata:type_name -> TaskResponse.MetadataEntry
+	17, // 1: TaskStatusReport.intermediatefiles:type_name -> TaskStatusReport.IntermediatefilesEntry
+	9,  // 2: JobStatus.maptasks:type_name -> TaskCounts
+	9,  // 3: JobStatus.reducetasks:type_name -> TaskCounts
+	10, // 4: JobStatus.assignments:type_name -> WorkerAssignment
+	11, // 5: ListJobsResponse.jobs:type_name -> JobStatus
+	0,  // 6: MasterApi.RegisterWorker:input_type -> RegisterWorkerRequest
+	2,  // 7: MasterApi.RequestTask:input_type -> TaskRequest
+	4,  // 8: MasterApi.ReportTaskStatus:input_type -> TaskStatusReport
+	6,  // 9: MasterApi.SubmitJob:input_type -> SubmitJobRequest
+	8,  // 10: MasterApi.GetJobStatus:input_type -> JobStatusRequest
+	12, // 11: MasterApi.CancelJob:input_type -> CancelJobRequest
+	14, // 12: MasterApi.ListJobs:input_type -> ListJobsRequest
+	1,  // 13: MasterApi.RegisterWorker:output_type -> RegisterWorkerResponse
+	3,  // 14: MasterApi.RequestTask:output_type -> TaskResponse
+	5,  // 15: MasterApi.ReportTaskStatus:output_type -> TaskStatusAck
+	7,  // 16: MasterApi.SubmitJob:output_type -> SubmitJobResponse
+	11, // 17: MasterApi.GetJobStatus:output_type -> JobStatus
+	13, // 18: MasterApi.CancelJob:output_type -> CancelJobResponse
+	15, // 19: MasterApi.ListJobs:output_type -> ListJobsResponse
+	13, // [13:20] is the sub-list for method output_type
+	6,  // [6:13] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_masterapi_proto_init() }
@@ -455,7 +1137,7 @@ func file_masterapi_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_masterapi_proto_rawDesc), len(file_masterapi_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
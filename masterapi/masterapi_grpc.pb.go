@@ -22,6 +22,10 @@ const (
 	MasterApi_RegisterWorker_FullMethodName   = "/MasterApi/RegisterWorker"
 	MasterApi_RequestTask_FullMethodName      = "/MasterApi/RequestTask"
 	MasterApi_ReportTaskStatus_FullMethodName = "/MasterApi/ReportTaskStatus"
+	MasterApi_SubmitJob_FullMethodName        = "/MasterApi/SubmitJob"
+	MasterApi_GetJobStatus_FullMethodName     = "/MasterApi/GetJobStatus"
+	MasterApi_CancelJob_FullMethodName        = "/MasterApi/CancelJob"
+	MasterApi_ListJobs_FullMethodName         = "/MasterApi/ListJobs"
 )
 
 // MasterApiClient is the client API for MasterApi service.
@@ -31,6 +35,10 @@ type MasterApiClient interface {
 	RegisterWorker(ctx context.Context, in *RegisterWorkerRequest, opts ...grpc.CallOption) (*RegisterWorkerResponse, error)
 	RequestTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*TaskResponse, error)
 	ReportTaskStatus(ctx context.Context, in *TaskStatusReport, opts ...grpc.CallOption) (*TaskStatusAck, error)
+	SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*SubmitJobResponse, error)
+	GetJobStatus(ctx context.Context, in *JobStatusRequest, opts ...grpc.CallOption) (*JobStatus, error)
+	CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*CancelJobResponse, error)
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
 }
 
 type masterApiClient struct {
@@ -71,6 +79,46 @@ func (c *masterApiClient) ReportTaskStatus(ctx context.Context, in *TaskStatusRe
 	return out, nil
 }
 
+func (c *masterApiClient) SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*SubmitJobResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitJobResponse)
+	err := c.cc.Invoke(ctx, MasterApi_SubmitJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *masterApiClient) GetJobStatus(ctx context.Context, in *JobStatusRequest, opts ...grpc.CallOption) (*JobStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobStatus)
+	err := c.cc.Invoke(ctx, MasterApi_GetJobStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *masterApiClient) CancelJob(ctx context.Context, in *CancelJobRequest, opts ...grpc.CallOption) (*CancelJobResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelJobResponse)
+	err := c.cc.Invoke(ctx, MasterApi_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *masterApiClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, MasterApi_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MasterApiServer is the server API for MasterApi service.
 // All implementations must embed UnimplementedMasterApiServer
 // for forward compatibility.
@@ -78,6 +126,10 @@ type MasterApiServer interface {
 	RegisterWorker(context.Context, *RegisterWorkerRequest) (*RegisterWorkerResponse, error)
 	RequestTask(context.Context, *TaskRequest) (*TaskResponse, error)
 	ReportTaskStatus(context.Context, *TaskStatusReport) (*TaskStatusAck, error)
+	SubmitJob(context.Context, *SubmitJobRequest) (*SubmitJobResponse, error)
+	GetJobStatus(context.Context, *JobStatusRequest) (*JobStatus, error)
+	CancelJob(context.Context, *CancelJobRequest) (*CancelJobResponse, error)
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
 	mustEmbedUnimplementedMasterApiServer()
 }
 
@@ -97,6 +149,18 @@ func (UnimplementedMasterApiServer) RequestTask(context.Context, *TaskRequest) (
 func (UnimplementedMasterApiServer) ReportTaskStatus(context.Context, *TaskStatusReport) (*TaskStatusAck, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReportTaskStatus not implemented")
 }
+func (UnimplementedMasterApiServer) SubmitJob(context.Context, *SubmitJobRequest) (*SubmitJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitJob not implemented")
+}
+func (UnimplementedMasterApiServer) GetJobStatus(context.Context, *JobStatusRequest) (*JobStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJobStatus not implemented")
+}
+func (UnimplementedMasterApiServer) CancelJob(context.Context, *CancelJobRequest) (*CancelJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedMasterApiServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
 func (UnimplementedMasterApiServer) mustEmbedUnimplementedMasterApiServer() {}
 func (UnimplementedMasterApiServer) testEmbeddedByValue()                   {}
 
@@ -172,6 +236,78 @@ func _MasterApi_ReportTaskStatus_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MasterApi_SubmitJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MasterApiServer).SubmitJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MasterApi_SubmitJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MasterApiServer).SubmitJob(ctx, req.(*SubmitJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MasterApi_GetJobStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MasterApiServer).GetJobStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MasterApi_GetJobStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MasterApiServer).GetJobStatus(ctx, req.(*JobStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MasterApi_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MasterApiServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MasterApi_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MasterApiServer).CancelJob(ctx, req.(*CancelJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MasterApi_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MasterApiServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MasterApi_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MasterApiServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MasterApi_ServiceDesc is the grpc.ServiceDesc for MasterApi service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -191,6 +327,22 @@ var MasterApi_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ReportTaskStatus",
 			Handler:    _MasterApi_ReportTaskStatus_Handler,
 		},
+		{
+			MethodName: "SubmitJob",
+			Handler:    _MasterApi_SubmitJob_Handler,
+		},
+		{
+			MethodName: "GetJobStatus",
+			Handler:    _MasterApi_GetJobStatus_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _MasterApi_CancelJob_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _MasterApi_ListJobs_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "masterapi.proto",
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Backend is where splits, intermediate files and outputs are stored. Names
+// are slash separated paths; every node of a job must use the same backend so
+// that a path written by one node can be opened by another.
+type Backend interface {
+	// Open opens the named file for reading.
+	Open(name string) (io.ReadCloser, error)
+	// OpenAt opens the named file for reading, starting at offset.
+	OpenAt(name string, offset int64) (io.ReadCloser, error)
+	// Create starts writing the named file. The data only becomes visible
+	// under name once the returned Writer is closed successfully.
+	Create(name string) (Writer, error)
+	// List returns the paths of the files directly inside dir, sorted.
+	List(dir string) ([]string, error)
+	// Remove deletes the named file.
+	Remove(name string) error
+	// Stat returns the size and modification time of the named file.
+	// Backends with real directories also report them.
+	Stat(name string) (FileInfo, error)
+}
+
+// Writer is returned by Backend.Create. Abort discards everything written so
+// far, so a failed task never leaves a partial file behind.
+type Writer interface {
+	io.WriteCloser
+	Abort() error
+}
+
+// FileInfo describes a stored file.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool // Only set by backends with real directories
+}
+
+// StorageBackendEnv names the environment variable that selects the default
+// storage backend.
+const StorageBackendEnv = "MAPREDUCE_STORAGE_BACKEND"
+
+// BackendSpec returns the backend spec from the environment, falling back to
+// the local filesystem.
+func BackendSpec() string {
+	if spec := os.Getenv(StorageBackendEnv); spec != "" {
+		return spec
+	}
+	return "local"
+}
+
+// NewBackend creates the backend described by spec:
+//
+//	local                                      the local filesystem
+//	s3://bucket?endpoint=http://host:9000      an S3 compatible object store
+//
+// The S3 spec also accepts a region parameter. Credentials are read from
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. MemoryBackend isn't offered
+// here because separate processes can't share it; tests create it directly.
+func NewBackend(spec string) (Backend, error) {
+	switch {
+	case spec == "" || spec == "local":
+		return NewLocalBackend(), nil
+	case strings.HasPrefix(spec, "s3://"):
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage backend %q: %v", spec, err)
+		}
+		query := u.Query()
+		return NewS3Backend(S3Config{
+			Endpoint:  query.Get("endpoint"),
+			Region:    query.Get("region"),
+			Bucket:    u.Host,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", spec)
+	}
+}
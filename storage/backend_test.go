@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+)
+
+// writeFile stores data under name and fails the test if that doesn't work.
+func writeFile(t *testing.T, backend Backend, name, data string) {
+	t.Helper()
+	w, err := backend.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the contents of name from offset on.
+func readFile(t *testing.T, backend Backend, name string, offset int64) string {
+	t.Helper()
+	r, err := backend.OpenAt(name, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestBackendConformance runs the same checks over every backend, so that a
+// node can't tell which one it was given.
+func TestBackendConformance(t *testing.T) {
+	backends := []struct {
+		name string
+		new  func(t *testing.T) (Backend, string)
+	}{
+		{"local", func(t *testing.T) (Backend, string) {
+			return NewLocalBackend(), t.TempDir()
+		}},
+		{"memory", func(t *testing.T) (Backend, string) {
+			return NewMemoryBackend(), "/data"
+		}},
+		{"s3", func(t *testing.T) (Backend, string) {
+			_, backend := newFakeS3(t)
+			return backend, "data"
+		}},
+	}
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			backend, root := b.new(t)
+			dir := path.Join(root, "job 1")
+			name := path.Join(dir, "part-0")
+			data := "hello, world\nsecond line\n"
+
+			writeFile(t, backend, name, data)
+			if got := readFile(t, backend, name, 0); got != data {
+				t.Errorf("read %q, want %q", got, data)
+			}
+			if got := readFile(t, backend, name, 7); got != data[7:] {
+				t.Errorf("read from offset 7 %q, want %q", got, data[7:])
+			}
+			r, err := backend.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || string(got) != data {
+				t.Errorf("Open read %q, %v, want %q", got, err, data)
+			}
+
+			info, err := backend.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size != int64(len(data)) {
+				t.Errorf("Stat size %d, want %d", info.Size, len(data))
+			}
+			if info.ModTime.IsZero() {
+				t.Errorf("Stat has no modification time")
+			}
+
+			// A file only appears once its writer is closed, and never if
+			// it is aborted
+			w, err := backend.Create(path.Join(dir, "part-1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, "partial")
+			if _, err := backend.Stat(path.Join(dir, "part-1")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Stat of an unclosed file returned %v, want fs.ErrNotExist", err)
+			}
+			if err := w.Abort(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := backend.Open(path.Join(dir, "part-1")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Open of an aborted file returned %v, want fs.ErrNotExist", err)
+			}
+
+			// List returns only the files directly inside the directory
+			writeFile(t, backend, path.Join(dir, "part-2"), "2")
+			writeFile(t, backend, path.Join(dir, "part-10"), "10")
+			writeFile(t, backend, path.Join(dir, "sub", "nested"), "nested")
+			writeFile(t, backend, path.Join(root, "job 10", "other"), "other")
+			names, err := backend.List(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{path.Join(dir, "part-0"), path.Join(dir, "part-10"), path.Join(dir, "part-2")}
+			if strings.Join(names, "|") != strings.Join(want, "|") {
+				t.Errorf("List returned %q, want %q", names, want)
+			}
+
+			if err := backend.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := backend.Open(name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Open of a removed file returned %v, want fs.ErrNotExist", err)
+			}
+			if _, err := backend.Stat(name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Stat of a removed file returned %v, want fs.ErrNotExist", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is a compression format input files may be stored in. None of the
+// supported codecs can be split, so a compressed input is read by a single
+// map task from start to end.
+type Codec struct {
+	Name       string
+	Extensions []string // File extensions, the first one is used for copies
+	magic      func(header []byte) bool
+	newReader  func(r io.Reader) (io.ReadCloser, error)
+}
+
+// magicSize is how many leading bytes are needed to recognise every codec.
+const magicSize = 10
+
+// bzip2 streams start with "BZh", the block size and the magic of either the
+// first block or the end of the stream.
+var (
+	bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+var codecs = []*Codec{
+	{
+		Name:       "gzip",
+		Extensions: []string{".gz", ".gzip"},
+		magic: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte{0x1f, 0x8b, 0x08})
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		Name:       "zstd",
+		Extensions: []string{".zst", ".zstd"},
+		magic: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd})
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			decoder, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	},
+	{
+		Name:       "bzip2",
+		Extensions: []string{".bz2", ".bzip2"},
+		magic: func(header []byte) bool {
+			return len(header) >= 10 && bytes.HasPrefix(header, []byte("BZh")) &&
+				header[3] >= '1' && header[3] <= '9' &&
+				(bytes.Equal(header[4:10], bzip2BlockMagic) || bytes.Equal(header[4:10], bzip2EndMagic))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+}
+
+// DetectCodec returns the codec of a file from its extension or, failing
+// that, from its first bytes. It returns nil for uncompressed files.
+func DetectCodec(name string, header []byte) *Codec {
+	ext := strings.ToLower(path.Ext(name))
+	for _, codec := range codecs {
+		for _, e := range codec.Extensions {
+			if ext == e {
+				return codec
+			}
+		}
+	}
+	for _, codec := range codecs {
+		if codec.magic(header) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// detectFileCodec returns the codec of a file of backend.
+func detectFileCodec(backend Backend, filePath string) (*Codec, error) {
+	file, err := backend.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, magicSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	return DetectCodec(filePath, header[:n]), nil
+}
+
+// OpenInput opens a whole input file and decompresses it while it is read
+// if it is compressed.
+func OpenInput(backend Backend, name string) (io.ReadCloser, error) {
+	file, err := backend.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	header, err := reader.Peek(magicSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	codec := DetectCodec(name, header)
+	if codec == nil {
+		return &inputReader{Reader: reader, closers: []io.Closer{file}}, nil
+	}
+	decompressed, err := codec.newReader(reader)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open %s as %s: %v", name, codec.Name, err)
+	}
+	return &inputReader{Reader: decompressed, closers: []io.Closer{decompressed, file}}, nil
+}
+
+// inputReader reads an input file through an optional decompressor and
+// closes both.
+type inputReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *inputReader) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressedRecords are the lines of every compressed test input. The bzip2
+// input is a fixture because the standard library can only decompress it.
+var compressedRecords = func() []string {
+	records := make([]string, 200)
+	for i := range records {
+		records[i] = fmt.Sprintf("record %d of the compressed input", i)
+	}
+	return records
+}()
+
+// compressedInput returns the test input compressed with codec.
+func compressedInput(t *testing.T, codec string) []byte {
+	t.Helper()
+	text := strings.Join(compressedRecords, "\n") + "\n"
+
+	var buf bytes.Buffer
+	switch codec {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		io.WriteString(w, text)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case "zstd":
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, text)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case "bzip2":
+		data, err := os.ReadFile(filepath.Join("testdata", "records.txt.bz2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	default:
+		t.Fatalf("unknown codec %s", codec)
+	}
+	return buf.Bytes()
+}
+
+// readRecords reads every record of the pieces of a split.
+func readRecords(t *testing.T, backend Backend, meta *InputFileMetadata) []string {
+	t.Helper()
+	var pieces []InputRange
+	for _, chunk := range meta.Chunks {
+		pieces = append(pieces, WholeFile(chunk))
+	}
+	pieces = append(pieces, meta.Ranges...)
+
+	var records []string
+	for _, piece := range pieces {
+		reader, file, err := OpenRecords(backend, piece, LineFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, string(record))
+		}
+		file.Close()
+	}
+	return records
+}
+
+func TestSplitCompressedInputs(t *testing.T) {
+	extensions := map[string]string{"gzip": ".gz", "zstd": ".zst", "bzip2": ".bz2"}
+	for _, codec := range []string{"gzip", "zstd", "bzip2"} {
+		for _, virtual := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/virtual=%v", codec, virtual), func(t *testing.T) {
+				root := t.TempDir()
+				s, err := NewSplitter(64, root, filepath.Join(root, "metadata.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.Virtual = virtual
+
+				input := filepath.Join(root, "input.txt"+extensions[codec])
+				if err := os.WriteFile(input, compressedInput(t, codec), 0644); err != nil {
+					t.Fatal(err)
+				}
+				meta, err := s.Split(input, LineFormat{})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if meta.Compression != codec {
+					t.Errorf("split compression %q, want %q", meta.Compression, codec)
+				}
+				// Compressed inputs can't be split, however small the chunk size
+				if pieces := len(meta.Chunks) + len(meta.Ranges); pieces != 1 {
+					t.Fatalf("split into %d pieces, want 1", pieces)
+				}
+				if virtual && meta.Ranges[0].Length >= 0 {
+					t.Errorf("virtual split range %+v, want the whole file", meta.Ranges[0])
+				}
+
+				got := readRecords(t, s.Storage, meta)
+				if strings.Join(got, "\n") != strings.Join(compressedRecords, "\n") {
+					t.Fatalf("read %d records, want the %d compressed ones", len(got), len(compressedRecords))
+				}
+			})
+		}
+	}
+}
+
+func TestDetectCodecFromMagic(t *testing.T) {
+	for _, codec := range []string{"gzip", "zstd", "bzip2"} {
+		data := compressedInput(t, codec)
+		if got := DetectCodec("input", data[:magicSize]); got == nil || got.Name != codec {
+			t.Errorf("DetectCodec of a %s header without an extension returned %v", codec, got)
+		}
+
+		// A file without an extension is still split as compressed
+		root := t.TempDir()
+		input := filepath.Join(root, "input")
+		if err := os.WriteFile(input, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewSplitter(64, root, filepath.Join(root, "metadata.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, err := s.Split(input, LineFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Compression != codec || len(meta.Chunks) != 1 {
+			t.Fatalf("%s input without an extension split as %q into %d chunks", codec, meta.Compression, len(meta.Chunks))
+		}
+		if got := readRecords(t, s.Storage, meta); len(got) != len(compressedRecords) {
+			t.Fatalf("read %d records of the %s input, want %d", len(got), codec, len(compressedRecords))
+		}
+	}
+
+	if got := DetectCodec("input.txt", []byte("record 0 of\n")); got != nil {
+		t.Errorf("DetectCodec of plain text returned %s", got.Name)
+	}
+	if got := DetectCodec("archive.GZ", []byte("not gzip")); got == nil || got.Name != "gzip" {
+		t.Errorf("DetectCodec of a .GZ name returned %v, want gzip", got)
+	}
+	// "BZh" alone is not enough to call a text file bzip2
+	if got := DetectCodec("notes", []byte("BZh9 is not a stream")); got != nil {
+		t.Errorf("DetectCodec of text starting with BZh returned %s", got.Name)
+	}
+}
@@ -1,31 +1,82 @@
 package storage
 
 import (
-	"bufio"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
-// InputFileMetadata represents metadata for one input file.
+// DefaultStorageRoot is the storage root used when none is configured. It
+// lives in the user's cache directory, which survives reboots and temporary
+// directory cleanups, so the split metadata and the master state that let a
+// restarted master resume its jobs aren't lost. Only if the platform has no
+// cache directory does it fall back to the temporary directory.
+var DefaultStorageRoot = defaultStorageRoot()
+
+func defaultStorageRoot() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "mapreduce_storage")
+}
+
+// StorageRootEnv names the environment variable that overrides the default
+// storage root.
+const StorageRootEnv = "MAPREDUCE_STORAGE_ROOT"
+
+// StorageRoot returns the storage root from the environment, falling back to
+// DefaultStorageRoot. Splits, intermediate files, outputs and metadata all
+// live underneath it.
+func StorageRoot() string {
+	if root := os.Getenv(StorageRootEnv); root != "" {
+		return root
+	}
+	return DefaultStorageRoot
+}
+
+// InputFileMetadata represents metadata for one input file. Size, ModTime
+// and Fingerprint describe the file as it was when it was split.
 type InputFileMetadata struct {
-	FileID   string   `json:"file_id"`
-	SplitDir string   `json:"split_dir"`
-	Chunks   []string `json:"chunks"`
+	FileID      string       `json:"file_id"`
+	SplitDir    string       `json:"split_dir"`
+	Chunks      []string     `json:"chunks"`
+	Ranges      []InputRange `json:"ranges,omitempty"`      // Byte ranges of the input file for virtual splits
+	Compression string       `json:"compression,omitempty"` // Codec of a compressed input, read by one map task
+	Format      string       `json:"format,omitempty"`      // Input format the file was split as, lines if empty
+	Size        int64        `json:"size"`
+	ModTime     time.Time    `json:"mod_time"`
+	Fingerprint string       `json:"fingerprint"`        // SHA-256 of the file content
+	Replaced    []string     `json:"replaced,omitempty"` // Split directories of earlier versions still referenced by jobs
 }
 
 // Splitter encapsulates the logic for file splitting and metadata handling.
+// Jobs reading a split hold a reference on its directory with Acquire, and
+// the chunks of a split replaced by a newer one are only removed once every
+// reference is released.
 type Splitter struct {
 	ChunkSize    int
+	Virtual      bool    // Record byte ranges of the input instead of writing chunk files
+	StorageRoot  string  // Splits are written to <StorageRoot>/splits
+	Storage      Backend // Backend the chunks are written to
 	MetadataPath string
 	Metadata     map[string]InputFileMetadata
+
+	mu   sync.Mutex     // Guards Metadata and refs
+	refs map[string]int // Split directory -> jobs reading it
 }
 
 // NewSplitter creates a new instance of Splitter and loads metadata if present.
-func NewSplitter(chunkSize int, metadataPath string) (*Splitter, error) {
+func NewSplitter(chunkSize int, storageRoot string, metadataPath string) (*Splitter, error) {
 	metadata := make(map[string]InputFileMetadata)
 	data, err := os.ReadFile(metadataPath)
 	if err == nil {
@@ -38,96 +89,355 @@ func NewSplitter(chunkSize int, metadataPath string) (*Splitter, error) {
 
 	return &Splitter{
 		ChunkSize:    chunkSize,
+		StorageRoot:  storageRoot,
+		Storage:      NewLocalBackend(),
 		MetadataPath: metadataPath,
 		Metadata:     metadata,
+		refs:         make(map[string]int),
 	}, nil
 }
 
-// hashFileName generates a short hash based on the base name of a file.
-func (s *Splitter) hashFileName(filePath string) string {
+// hashFilePath generates a short hash of the absolute path of a file, so
+// files with the same base name in different directories don't share a
+// split directory.
+func (s *Splitter) hashFilePath(absPath string) string {
 	h := sha1.New()
-	h.Write([]byte(filepath.Base(filePath)))
+	h.Write([]byte(absPath))
 	return hex.EncodeToString(h.Sum(nil))[:6]
 }
 
-// Split splits the file and updates metadata.
-func (s *Splitter) Split(filePath string) (*InputFileMetadata, error) {
-	if meta, exists := s.Metadata[filePath]; exists {
-		fmt.Printf("Skipping split for %s (already registered)\n", filePath)
-		return &meta, nil
+// fingerprintFile returns the SHA-256 of the content of a file of backend.
+func fingerprintFile(backend Backend, filePath string) (string, error) {
+	file, err := backend.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %v", err)
 	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	file, err := os.Open(filePath)
+// Split splits the file at record boundaries of format and updates metadata.
+// An earlier split of the same path is reused only if it used the same format
+// and the file still has the same size, modification time and content
+// fingerprint. Otherwise the file is split again into a new directory and the
+// stale chunks are removed once no job references them. The file is read
+// through the storage backend, like every node reads it. Virtual splits only
+// record ranges of the input file, and map tasks read the input file itself.
+// Compressed inputs can't be split: they are copied as a single chunk, or
+// referenced as a single range, and read by one map task.
+//
+// The file is hashed and split without holding the splitter lock, so jobs
+// acquiring and releasing splits aren't held up by a large input.
+func (s *Splitter) Split(filePath string, format InputFormat) (*InputFileMetadata, error) {
+	absPath, err := absPath(s.Storage, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %v", err)
+		return nil, err
+	}
+	info, err := s.Storage.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %v", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var currentChunk []byte
-	currentSize := 0
-	chunkIndex := 0
+	// Size and modification time rule out most changes without reading the
+	// file; the fingerprint catches rewrites that keep both.
+	s.mu.Lock()
+	cached, exists := s.Metadata[absPath]
+	s.mu.Unlock()
+	var fingerprint string
+	if exists && s.unchanged(cached, info, format) {
+		if fingerprint, err = fingerprintFile(s.Storage, absPath); err != nil {
+			return nil, err
+		}
+		if fingerprint == cached.Fingerprint {
+			if current, ok := s.current(absPath, cached); ok {
+				fmt.Printf("Skipping split for %s (already registered)\n", filePath)
+				return current, nil
+			}
+		}
+	}
+	if exists {
+		fmt.Printf("%s changed since it was split, splitting it again as %s\n", filePath, format.Name())
+	}
+	if fingerprint == "" {
+		if fingerprint, err = fingerprintFile(s.Storage, absPath); err != nil {
+			return nil, err
+		}
+	}
 
-	fileID := fmt.Sprintf("input-%s", s.hashFileName(filePath))
-	outputDir := filepath.Join("/Volumes/mapreduce_storage/splits", fileID)
+	// The split directory is referenced until the split is registered, so an
+	// earlier split of the same content that is being replaced isn't removed
+	// while chunks are written to it
+	fileID := fmt.Sprintf("input-%s-%s", s.hashFilePath(absPath), fingerprint[:8])
+	var splitDir string
+	if !s.Virtual {
+		splitDir = filepath.Join(s.StorageRoot, "splits", fileID)
+		s.Acquire(splitDir)
+	}
+	meta, err := s.split(absPath, fileID, info.Size, format)
+	if err == nil {
+		meta.Size, meta.ModTime, meta.Fingerprint, meta.Format = info.Size, info.ModTime, fingerprint, format.Name()
+		meta, err = s.register(absPath, meta)
+	}
+	if splitDir != "" {
+		if releaseErr := s.Release(splitDir); err == nil && releaseErr != nil {
+			return nil, releaseErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// unchanged reports whether an earlier split was made as format, virtual or
+// not like the splitter, of a file with the size and modification time in
+// info.
+func (s *Splitter) unchanged(cached InputFileMetadata, info FileInfo, format InputFormat) bool {
+	cachedFormat := cached.Format
+	if cachedFormat == "" {
+		cachedFormat = DefaultInputFormat
+	}
+	return cached.Size == info.Size && cached.ModTime.Equal(info.ModTime) &&
+		(cached.Ranges != nil) == s.Virtual && cachedFormat == format.Name()
+}
+
+// current returns the registered split of absPath if it is still the cached
+// one, which another split of the same path may have replaced meanwhile.
+func (s *Splitter) current(absPath string, cached InputFileMetadata) (*InputFileMetadata, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.Metadata[absPath]
+	if !ok || current.Fingerprint != cached.Fingerprint || current.SplitDir != cached.SplitDir {
+		return nil, false
+	}
+	return &current, true
+}
 
-	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("failed to create split directory: %v", err)
+// split writes the chunks, or records the ranges, of a new split of absPath.
+func (s *Splitter) split(absPath string, fileID string, size int64, format InputFormat) (*InputFileMetadata, error) {
+	codec, err := detectFileCodec(s.Storage, absPath)
+	if err != nil {
+		return nil, err
+	}
+	if codec != nil {
+		return s.splitCompressed(absPath, fileID, codec)
 	}
 
+	ranges, err := splitRanges(s.Storage, absPath, size, s.ChunkSize, format)
+	if err != nil {
+		return nil, err
+	}
 	meta := &InputFileMetadata{
-		FileID:   fileID,
-		SplitDir: outputDir,
-		Chunks:   []string{},
+		FileID: fileID,
+		Chunks: []string{},
+	}
+	if s.Virtual {
+		meta.Ranges = ranges
+		return meta, nil
 	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineBytes := append([]byte(line), '\n')
+	// Every version of the content gets its own directory, so chunks left
+	// over from an older, longer version never end up among the new ones
+	meta.SplitDir = filepath.Join(s.StorageRoot, "splits", fileID)
+	if err := s.writeChunks(absPath, meta, ranges, format); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// register saves the metadata of a new split. The split it replaces is kept
+// until no job references it.
+func (s *Splitter) register(absPath string, meta *InputFileMetadata) (*InputFileMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		if currentSize+len(lineBytes) > s.ChunkSize && currentSize > 0 {
-			chunkPath := filepath.Join(outputDir, fmt.Sprintf("chunk-%04d.txt", chunkIndex))
-			if err := os.WriteFile(chunkPath, currentChunk, 0644); err != nil {
-				return nil, fmt.Errorf("failed to write chunk: %v", err)
+	if replaced, exists := s.Metadata[absPath]; exists {
+		// The new split may reuse the directory of an earlier version with
+		// the same content, which must not be removed any more
+		for _, dir := range replaced.Replaced {
+			if dir != meta.SplitDir {
+				meta.Replaced = append(meta.Replaced, dir)
 			}
-			meta.Chunks = append(meta.Chunks, chunkPath)
-			chunkIndex++
-			currentChunk = []byte{}
-			currentSize = 0
 		}
+		if replaced.SplitDir != "" && replaced.SplitDir != meta.SplitDir {
+			meta.Replaced = append(meta.Replaced, replaced.SplitDir)
+		}
+	}
+	s.Metadata[absPath] = *meta
+	s.removeReplaced()
+	if err := s.saveMetadata(); err != nil {
+		return nil, err
+	}
 
-		currentChunk = append(currentChunk, lineBytes...)
-		currentSize += len(lineBytes)
+	registered := s.Metadata[absPath]
+	return &registered, nil
+}
+
+// Acquire records that a job reads the chunks in the given split
+// directories, so they are kept if their inputs are split again. Virtual
+// splits have no directory and need no reference.
+func (s *Splitter) Acquire(splitDirs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dir := range splitDirs {
+		s.refs[dir]++
 	}
+}
 
-	if len(currentChunk) > 0 {
-		chunkPath := filepath.Join(outputDir, fmt.Sprintf("chunk-%04d.txt", chunkIndex))
-		if err := os.WriteFile(chunkPath, currentChunk, 0644); err != nil {
-			return nil, fmt.Errorf("failed to write last chunk: %v", err)
+// Release drops references taken with Acquire and removes the replaced
+// splits no job references any more.
+func (s *Splitter) Release(splitDirs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dir := range splitDirs {
+		if s.refs[dir]--; s.refs[dir] <= 0 {
+			delete(s.refs, dir)
 		}
-		meta.Chunks = append(meta.Chunks, chunkPath)
 	}
+	if !s.removeReplaced() {
+		return nil
+	}
+	return s.saveMetadata()
+}
 
-	s.Metadata[filePath] = *meta
-	if err := s.saveMetadata(); err != nil {
-		return nil, err
+// removeReplaced deletes the chunks of replaced splits without references
+// and reports whether any were removed. A split that can't be removed stays
+// listed and is tried again later. s.mu must be held.
+func (s *Splitter) removeReplaced() bool {
+	changed := false
+	for path, meta := range s.Metadata {
+		var kept []string
+		for _, dir := range meta.Replaced {
+			if s.refs[dir] > 0 {
+				kept = append(kept, dir)
+				continue
+			}
+			if err := s.removeSplitDir(dir); err != nil {
+				fmt.Printf("Failed to remove replaced split %s: %v\n", dir, err)
+				kept = append(kept, dir)
+				continue
+			}
+			changed = true
+		}
+		meta.Replaced = kept
+		s.Metadata[path] = meta
+	}
+	return changed
+}
+
+// removeSplitDir deletes the chunks in a split directory. A directory that
+// is already gone counts as removed.
+func (s *Splitter) removeSplitDir(dir string) error {
+	chunks, err := s.Storage.List(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
+	for _, chunk := range chunks {
+		if err := s.Storage.Remove(chunk); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	fmt.Printf("Removed %d stale chunks of %s\n", len(chunks), dir)
+	return nil
+}
 
+// splitCompressed splits a compressed input into a single piece: a range
+// covering the whole file for virtual splits, otherwise a copy of the file in
+// the split directory.
+func (s *Splitter) splitCompressed(absPath string, fileID string, codec *Codec) (*InputFileMetadata, error) {
+	meta := &InputFileMetadata{
+		FileID:      fileID,
+		Chunks:      []string{},
+		Compression: codec.Name,
+	}
+	if s.Virtual {
+		meta.Ranges = []InputRange{WholeFile(absPath)}
+		return meta, nil
+	}
+
+	meta.SplitDir = filepath.Join(s.StorageRoot, "splits", fileID)
+	chunkPath := filepath.Join(meta.SplitDir, "chunk-0000"+codec.Extensions[0])
+
+	file, err := s.Storage.Open(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	w, err := s.Storage.Create(chunkPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write chunk: %v", err)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		w.Abort()
+		return nil, fmt.Errorf("failed to write chunk: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write chunk: %v", err)
+	}
+	meta.Chunks = append(meta.Chunks, chunkPath)
 	return meta, nil
 }
 
-// saveMetadata writes the updated metadata to disk.
+// writeChunks copies the ranges of a file into chunk files in the split
+// directory, streaming them so records of any length can be split.
+func (s *Splitter) writeChunks(absPath string, meta *InputFileMetadata, ranges []InputRange, format InputFormat) error {
+	file, err := s.Storage.Open(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	for i, r := range ranges {
+		chunkPath := filepath.Join(meta.SplitDir, fmt.Sprintf("chunk-%04d%s", i, chunkExtension(format)))
+		w, err := s.Storage.Create(chunkPath)
+		if err != nil {
+			return fmt.Errorf("failed to write chunk: %v", err)
+		}
+		if _, err := io.CopyN(w, file, r.Length); err != nil {
+			w.Abort()
+			return fmt.Errorf("failed to write chunk: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to write chunk: %v", err)
+		}
+		meta.Chunks = append(meta.Chunks, chunkPath)
+	}
+	return nil
+}
+
+// chunkExtension returns the file extension of the chunks of format.
+func chunkExtension(format InputFormat) string {
+	switch format.(type) {
+	case LineFormat:
+		return ".txt"
+	case BinaryFormat:
+		return ".bin"
+	}
+	return "." + format.Name()
+}
+
+// saveMetadata writes the updated metadata to disk. The file is replaced
+// atomically, so a crash while saving leaves the previous metadata intact
+// rather than a truncated file NewSplitter can't parse.
 func (s *Splitter) saveMetadata() error {
 	data, err := json.MarshalIndent(s.Metadata, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %v", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(s.MetadataPath), os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create metadata directory: %v", err)
-	}
-
-	if err := os.WriteFile(s.MetadataPath, data, 0644); err != nil {
+	if err := WriteFileAtomic(s.MetadataPath, data); err != nil {
 		return fmt.Errorf("failed to write metadata file: %v", err)
 	}
 
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// splitFile writes content to path and splits it.
+func splitFile(t *testing.T, s *Splitter, path, content string) *InputFileMetadata {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := s.Split(path, LineFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
+
+func TestSplitKeepsReplacedChunksUntilReleased(t *testing.T) {
+	root := t.TempDir()
+	metadataPath := filepath.Join(root, "metadata.json")
+	s, err := NewSplitter(16, root, metadataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(root, "input.txt")
+
+	first := splitFile(t, s, input, "one two three\nfour five six\n")
+	s.Acquire(first.SplitDir)
+
+	// A job still reads the first split, so splitting a changed input
+	// must leave its chunks in place
+	second := splitFile(t, s, input, "seven eight nine\nten eleven\n")
+	if second.SplitDir == first.SplitDir {
+		t.Fatalf("changed input reused split directory %s", first.SplitDir)
+	}
+	for _, chunk := range first.Chunks {
+		if _, err := os.Stat(chunk); err != nil {
+			t.Fatalf("chunk of a referenced split was removed: %v", err)
+		}
+	}
+
+	// The pending removal survives a restart
+	reloaded, err := NewSplitter(16, root, metadataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs, _ := filepath.Abs(input)
+	if got := reloaded.Metadata[abs].Replaced; len(got) != 1 || got[0] != first.SplitDir {
+		t.Fatalf("metadata lists replaced splits %v, want [%s]", got, first.SplitDir)
+	}
+
+	if err := s.Release(first.SplitDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range first.Chunks {
+		if _, err := os.Stat(chunk); !os.IsNotExist(err) {
+			t.Fatalf("chunk %s of a released split still exists", chunk)
+		}
+	}
+	for _, chunk := range second.Chunks {
+		if _, err := os.Stat(chunk); err != nil {
+			t.Fatalf("chunk of the current split was removed: %v", err)
+		}
+	}
+	if got := s.Metadata[abs].Replaced; len(got) != 0 {
+		t.Fatalf("metadata still lists replaced splits %v", got)
+	}
+
+	// Without a reference the replaced split goes at once
+	third := splitFile(t, s, input, "twelve\n")
+	for _, chunk := range second.Chunks {
+		if _, err := os.Stat(chunk); !os.IsNotExist(err) {
+			t.Fatalf("chunk %s of an unreferenced split still exists", chunk)
+		}
+	}
+	if len(third.Chunks) == 0 {
+		t.Fatal("third split has no chunks")
+	}
+}
+
+func TestSplitOfRevertedInputKeepsItsDirectory(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewSplitter(16, root, filepath.Join(root, "metadata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(root, "input.txt")
+
+	first := splitFile(t, s, input, "one two three\nfour five six\n")
+	s.Acquire(first.SplitDir)
+	splitFile(t, s, input, "seven eight nine\n")
+
+	// Restoring the first content splits it into its directory again, which
+	// is current once more and must outlive the job releasing it
+	reverted := splitFile(t, s, input, "one two three\nfour five six\n")
+	if reverted.SplitDir != first.SplitDir {
+		t.Fatalf("reverted input split into %s, want %s", reverted.SplitDir, first.SplitDir)
+	}
+	if err := s.Release(first.SplitDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range reverted.Chunks {
+		if _, err := os.Stat(chunk); err != nil {
+			t.Fatalf("chunk of the current split was removed: %v", err)
+		}
+	}
+}
+
+// blockingBackend holds up reads of its files until unblock is closed.
+type blockingBackend struct {
+	Backend
+	opened  chan struct{}
+	unblock chan struct{}
+}
+
+func (b *blockingBackend) Open(name string) (io.ReadCloser, error) {
+	select {
+	case b.opened <- struct{}{}:
+	default:
+	}
+	<-b.unblock
+	return b.Backend.Open(name)
+}
+
+func TestSplitDoesNotBlockRelease(t *testing.T) {
+	backend := &blockingBackend{
+		Backend: NewMemoryBackend(),
+		opened:  make(chan struct{}, 1),
+		unblock: make(chan struct{}),
+	}
+	w, err := backend.Create("input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("one two three\nfour five six\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	s, err := NewSplitter(16, root, filepath.Join(root, "metadata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Storage = backend
+
+	split := make(chan error, 1)
+	go func() {
+		_, err := s.Split("input.txt", LineFormat{})
+		split <- err
+	}()
+	<-backend.opened
+
+	// The split is reading the input, and jobs must still be able to
+	// release theirs meanwhile
+	released := make(chan error, 1)
+	go func() { released <- s.Release("splits/other") }()
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Release waited for the input to be split")
+	}
+
+	close(backend.unblock)
+	if err := <-split; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,368 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// InputFormat reads the records of an input file and knows where a split of
+// the file may safely begin. The splitter cuts files at record boundaries
+// found by Align and map tasks read their split with NewReader, so a record
+// is never broken across map tasks.
+type InputFormat interface {
+	// Name selects the format in job specs and task metadata.
+	Name() string
+	// NewReader returns a reader of the records in r, which must start at a
+	// record boundary.
+	NewReader(r io.Reader) RecordReader
+	// Align returns the first record boundary at or after offset, next, and
+	// the start of the record holding the byte before offset, recordStart.
+	// start is a record boundary before offset, from which formats that
+	// can't resynchronize in the middle of a file read their way forward;
+	// recordStart is never before it.
+	Align(file io.ReadSeeker, start, offset int64) (recordStart, next int64, err error)
+}
+
+// RecordReader returns the records of an input one at a time.
+type RecordReader interface {
+	// Read returns the next record, or io.EOF after the last one. The record
+	// is only valid until the next call.
+	Read() ([]byte, error)
+}
+
+// DefaultInputFormat is the format of jobs that don't name one.
+const DefaultInputFormat = "lines"
+
+var inputFormats = map[string]InputFormat{
+	"lines":  LineFormat{},
+	"csv":    CSVFormat{},
+	"jsonl":  JSONLinesFormat{},
+	"binary": BinaryFormat{},
+}
+
+// LookupInputFormat returns the input format called name, or the default
+// format if name is empty.
+func LookupInputFormat(name string) (InputFormat, error) {
+	if name == "" {
+		name = DefaultInputFormat
+	}
+	format, ok := inputFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown input format %q, want one of %s", name, strings.Join(InputFormatNames(), ", "))
+	}
+	return format, nil
+}
+
+// InputFormatNames returns the names of the built-in input formats, sorted.
+func InputFormatNames() []string {
+	names := make([]string, 0, len(inputFormats))
+	for name := range inputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenRecords opens the records of an input range read as format. A whole
+// file range is decompressed if the file is compressed. Ranges of newline
+// delimited formats are read like Hadoop's line reader, see OpenInputRange;
+// other formats rely on the range starting and ending at record boundaries,
+// as the splitter cuts them.
+func OpenRecords(backend Backend, r InputRange, format InputFormat) (RecordReader, io.Closer, error) {
+	var (
+		file io.ReadCloser
+		err  error
+	)
+	switch {
+	case r.Length < 0:
+		file, err = OpenInput(backend, r.File)
+	case isNewlineDelimited(format):
+		file, err = OpenInputRange(backend, r)
+	default:
+		var f io.ReadCloser
+		if f, err = backend.OpenAt(r.File, r.Offset); err == nil {
+			file = &inputReader{Reader: io.LimitReader(f, r.Length), closers: []io.Closer{f}}
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return format.NewReader(file), file, nil
+}
+
+// isNewlineDelimited reports whether every newline of format ends a record.
+func isNewlineDelimited(format InputFormat) bool {
+	switch format.(type) {
+	case LineFormat, JSONLinesFormat:
+		return true
+	}
+	return false
+}
+
+// alignToLine returns the start of the line holding byte offset-1, found by
+// scanning back no further than start, and the offset following the first
+// newline at or after offset-1, the start of the first line beginning at or
+// after offset.
+func alignToLine(file io.ReadSeeker, start, offset int64) (int64, int64, error) {
+	if offset <= start {
+		return start, start, nil
+	}
+	lineStart, err := lastLineStart(file, start, offset-1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pos := offset - 1
+	if _, err := file.Seek(pos, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to seek: %v", err)
+	}
+	reader := bufio.NewReader(file)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		pos += int64(len(chunk))
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return 0, 0, fmt.Errorf("failed to read file: %v", err)
+		}
+		return lineStart, pos, nil
+	}
+}
+
+// lastLineStart returns the offset following the last newline in
+// [start, end), or start if there is none. It reads backwards in blocks, so
+// only the line ending at end is read.
+func lastLineStart(file io.ReadSeeker, start, end int64) (int64, error) {
+	block := make([]byte, 4096)
+	for end > start {
+		n := min(int64(len(block)), end-start)
+		if _, err := file.Seek(end-n, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("failed to seek: %v", err)
+		}
+		if _, err := io.ReadFull(file, block[:n]); err != nil {
+			return 0, fmt.Errorf("failed to read file: %v", err)
+		}
+		if i := bytes.LastIndexByte(block[:n], '\n'); i >= 0 {
+			return end - n + int64(i) + 1, nil
+		}
+		end -= n
+	}
+	return start, nil
+}
+
+// LineFormat reads newline delimited text. Records are the lines without
+// their newline.
+type LineFormat struct{}
+
+func (LineFormat) Name() string { return "lines" }
+
+func (LineFormat) NewReader(r io.Reader) RecordReader {
+	return &lineReader{reader: bufio.NewReader(r)}
+}
+
+func (LineFormat) Align(file io.ReadSeeker, start, offset int64) (int64, int64, error) {
+	return alignToLine(file, start, offset)
+}
+
+// lineReader reads lines of any length.
+type lineReader struct {
+	reader *bufio.Reader
+	line   []byte
+}
+
+func (r *lineReader) Read() ([]byte, error) {
+	r.line = r.line[:0]
+	for {
+		fragment, err := r.reader.ReadSlice('\n')
+		r.line = append(r.line, fragment...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(r.line) == 0 {
+			return nil, io.EOF
+		}
+		return bytes.TrimSuffix(r.line, []byte{'\n'}), nil
+	}
+}
+
+// JSONLinesFormat reads JSON Lines: one JSON value per line. JSON strings
+// can't hold a raw newline, so splits are aligned like lines. Blank lines are
+// skipped and a line that isn't valid JSON is an error.
+type JSONLinesFormat struct{}
+
+func (JSONLinesFormat) Name() string { return "jsonl" }
+
+func (JSONLinesFormat) NewReader(r io.Reader) RecordReader {
+	return &jsonLinesReader{lines: lineReader{reader: bufio.NewReader(r)}}
+}
+
+func (JSONLinesFormat) Align(file io.ReadSeeker, start, offset int64) (int64, int64, error) {
+	return alignToLine(file, start, offset)
+}
+
+type jsonLinesReader struct {
+	lines  lineReader
+	record int // Lines read so far
+}
+
+func (r *jsonLinesReader) Read() ([]byte, error) {
+	for {
+		line, err := r.lines.Read()
+		if err != nil {
+			return nil, err
+		}
+		r.record++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("line %d of the split is not valid JSON", r.record)
+		}
+		return line, nil
+	}
+}
+
+// CSVFormat reads RFC 4180 CSV. A record ends at a CRLF or LF outside a
+// quoted field, so quoted fields may hold newlines. Records are passed on as
+// CSV text without their line ending, for the mapper to parse with
+// encoding/csv. Whether a newline is quoted depends on everything before it,
+// so splits are aligned by reading records from the previous boundary.
+type CSVFormat struct{}
+
+func (CSVFormat) Name() string { return "csv" }
+
+func (CSVFormat) NewReader(r io.Reader) RecordReader {
+	return &csvReader{reader: bufio.NewReader(r)}
+}
+
+func (CSVFormat) Align(file io.ReadSeeker, start, offset int64) (int64, int64, error) {
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to seek: %v", err)
+	}
+	reader := &csvReader{reader: bufio.NewReader(file)}
+	recordStart, pos := start, start
+	for pos < offset {
+		if _, err := reader.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, 0, fmt.Errorf("failed to read CSV record at offset %d: %v", pos, err)
+		}
+		recordStart = pos
+		pos += reader.size
+	}
+	return recordStart, pos, nil
+}
+
+type csvReader struct {
+	reader *bufio.Reader
+	record []byte
+	size   int64 // Bytes of the last record, including its line ending
+}
+
+func (r *csvReader) Read() ([]byte, error) {
+	r.record = r.record[:0]
+	quoted := false
+	for {
+		fragment, err := r.reader.ReadSlice('\n')
+		for _, c := range fragment {
+			// A doubled quote inside a quoted field toggles twice
+			if c == '"' {
+				quoted = !quoted
+			}
+		}
+		r.record = append(r.record, fragment...)
+		if err == bufio.ErrBufferFull || (err == nil && quoted) {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(r.record) == 0 {
+			return nil, io.EOF
+		}
+		if quoted {
+			return nil, fmt.Errorf("unterminated quoted field in record %q", truncate(r.record, 40))
+		}
+		r.size = int64(len(r.record))
+		record := bytes.TrimSuffix(r.record, []byte{'\n'})
+		return bytes.TrimSuffix(record, []byte{'\r'}), nil
+	}
+}
+
+// truncate shortens data for error messages.
+func truncate(data []byte, n int) string {
+	if len(data) <= n {
+		return string(data)
+	}
+	return string(data[:n]) + "..."
+}
+
+// BinaryFormat reads length-prefixed binary records: a 4 byte big-endian
+// length followed by that many bytes of payload. Records are the payloads.
+// Splits are aligned by hopping from length to length from the previous
+// boundary.
+type BinaryFormat struct{}
+
+func (BinaryFormat) Name() string { return "binary" }
+
+func (BinaryFormat) NewReader(r io.Reader) RecordReader {
+	return &binaryReader{reader: bufio.NewReader(r)}
+}
+
+func (BinaryFormat) Align(file io.ReadSeeker, start, offset int64) (int64, int64, error) {
+	recordStart, pos := start, start
+	var header [4]byte
+	for pos < offset {
+		if _, err := file.Seek(pos, io.SeekStart); err != nil {
+			return 0, 0, fmt.Errorf("failed to seek: %v", err)
+		}
+		n, err := io.ReadFull(file, header[:])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("truncated record length at offset %d (%d bytes)", pos, n)
+		}
+		recordStart = pos
+		pos += int64(len(header)) + int64(binary.BigEndian.Uint32(header[:]))
+	}
+	return recordStart, pos, nil
+}
+
+type binaryReader struct {
+	reader *bufio.Reader
+	record bytes.Buffer
+}
+
+func (r *binaryReader) Read() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated record length")
+		}
+		return nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(header[:]))
+
+	// Grow the buffer as the payload arrives rather than trusting the
+	// length up front, so a corrupt length fails without a huge allocation
+	r.record.Reset()
+	if n, err := io.CopyN(&r.record, r.reader, size); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("truncated record: got %d of %d bytes", n, size)
+		}
+		return nil, err
+	}
+	return r.record.Bytes(), nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExpandInputs turns the inputs of a job into the list of files to split,
+// looking them up in backend. Every input is a file, a directory, whose
+// files are used without descending into subdirectories, or a glob pattern.
+// On the local filesystem the pattern may be any filepath.Match pattern;
+// other backends can only list files, so there only the last path element
+// may hold a pattern. Hidden files inside directories are skipped. Files are
+// returned in the order of the inputs, each input's matches sorted, and a
+// file named twice is only split once. An input that matches no file is an
+// error.
+func ExpandInputs(backend Backend, inputs []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(name string) error {
+		abs, err := absPath(backend, name)
+		if err != nil {
+			return err
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			files = append(files, name)
+		}
+		return nil
+	}
+
+	for _, input := range inputs {
+		matches, err := expandInput(backend, input)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("input %s matches no files", input)
+		}
+		for _, match := range matches {
+			if err := add(match); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// absPath returns the name under which a file of backend is known, so the
+// same file reached through different paths is recognized. Local paths are
+// made absolute; the names of other backends have no working directory to
+// be relative to and are only cleaned.
+func absPath(backend Backend, name string) (string, error) {
+	if _, ok := backend.(*LocalBackend); !ok {
+		return path.Clean(name), nil
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", name, err)
+	}
+	return abs, nil
+}
+
+// expandInput returns the files one input stands for.
+func expandInput(backend Backend, input string) ([]string, error) {
+	paths := []string{input}
+	if strings.ContainsAny(input, "*?[") {
+		matches, err := glob(backend, input)
+		if err != nil {
+			return nil, err
+		}
+		paths = matches
+	}
+
+	var files []string
+	for _, name := range paths {
+		info, err := backend.Stat(name)
+		switch {
+		case err == nil && !info.IsDir:
+			files = append(files, name)
+			continue
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("failed to stat input %s: %v", name, err)
+		}
+
+		// Either a directory or, on object stores, a prefix of other files
+		entries, err := backend.List(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read input directory %s: %v", name, err)
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(path.Base(entry), ".") {
+				files = append(files, entry)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// glob returns the names matching pattern in backend.
+func glob(backend Backend, pattern string) ([]string, error) {
+	if _, ok := backend.(*LocalBackend); ok {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input pattern %s: %v", pattern, err)
+		}
+		return matches, nil
+	}
+
+	dir, base := path.Split(pattern)
+	if strings.ContainsAny(dir, "*?[") {
+		return nil, fmt.Errorf("invalid input pattern %s: only the last path element may hold a pattern", pattern)
+	}
+	if _, err := path.Match(base, ""); err != nil {
+		return nil, fmt.Errorf("invalid input pattern %s: %v", pattern, err)
+	}
+	entries, err := backend.List(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list %s: %v", dir, err)
+	}
+	var matches []string
+	for _, entry := range entries {
+		if ok, _ := path.Match(base, path.Base(entry)); ok {
+			matches = append(matches, entry)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandInputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  []string
+		want    []string // Relative to the input directory
+		wantErr string   // Part of the expected error
+	}{
+		{"directory skips hidden files and subdirectories", []string{"in"}, []string{"in/a.txt", "in/b.txt", "in/c.csv"}, ""},
+		{"glob matches are sorted", []string{"in/[cba].*"}, []string{"in/a.txt", "in/b.txt", "in/c.csv"}, ""},
+		{"inputs keep their order", []string{"in/c.csv", "in/*.txt"}, []string{"in/c.csv", "in/a.txt", "in/b.txt"}, ""},
+		{"file named twice is kept once", []string{"in/b.txt", "in", "in/./a.txt"}, []string{"in/b.txt", "in/a.txt", "in/c.csv"}, ""},
+		{"glob matching nothing", []string{"in/a.txt", "in/*.json"}, nil, "matches no files"},
+		{"missing file", []string{"in/missing.txt"}, nil, "matches no files"},
+		{"invalid pattern", []string{"in/[a"}, nil, "invalid input pattern"},
+	}
+
+	backends := []struct {
+		name    string
+		backend Backend
+		root    string
+	}{
+		{"local", NewLocalBackend(), t.TempDir()},
+		{"memory", NewMemoryBackend(), "data"},
+	}
+	for _, b := range backends {
+		for _, name := range []string{"in/b.txt", "in/a.txt", "in/c.csv", "in/.hidden", "in/sub/d.txt"} {
+			writeFile(t, b.backend, filepath.Join(b.root, name), name)
+		}
+
+		for _, tt := range tests {
+			inputs := make([]string, len(tt.inputs))
+			for i, input := range tt.inputs {
+				inputs[i] = b.root + "/" + input
+			}
+			got, err := ExpandInputs(b.backend, inputs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("%s, %s: got %v, %v, want error %q", b.name, tt.name, got, err, tt.wantErr)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s, %s: %v", b.name, tt.name, err)
+				continue
+			}
+			for i := range got {
+				got[i] = strings.TrimPrefix(filepath.ToSlash(got[i]), b.root+"/")
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("%s, %s: got %v, want %v", b.name, tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestExpandInputsPatternOnlyInLastElementOfObjectStores(t *testing.T) {
+	backend := NewMemoryBackend()
+	writeFile(t, backend, "data/in/a.txt", "a")
+	if _, err := ExpandInputs(backend, []string{"data/*/a.txt"}); err == nil || !strings.Contains(err.Error(), "only the last path element") {
+		t.Fatalf("got %v, want an error about the pattern", err)
+	}
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// InputRange is the part of an input file read by one map task. A negative
+// Length stands for the whole file.
+type InputRange struct {
+	File   string `json:"file"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// WholeFile returns the range covering all of a file.
+func WholeFile(file string) InputRange {
+	return InputRange{File: file, Length: -1}
+}
+
+// OpenInputRange opens the lines of an input range. Like Hadoop's line
+// reader it reads the lines that start inside the range: a range that
+// doesn't start at the beginning of the file skips the line it starts in,
+// which belongs to the previous range, and the last line is read past the
+// end of the range up to its newline. Every line is therefore read by
+// exactly one range, wherever the range boundaries fall. A whole file range
+// is decompressed if the file is compressed.
+func OpenInputRange(backend Backend, r InputRange) (io.ReadCloser, error) {
+	if r.Length < 0 {
+		return OpenInput(backend, r.File)
+	}
+
+	// Start one byte early, so a range starting right after a newline
+	// keeps its first line.
+	start := max(r.Offset-1, 0)
+	file, err := backend.OpenAt(r.File, start)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &rangeLineReader{
+		file:   file,
+		reader: bufio.NewReader(file),
+		pos:    start,
+		end:    r.Offset + r.Length,
+	}
+	if r.Offset > 0 {
+		skipped, err := reader.reader.ReadSlice('\n')
+		for err == bufio.ErrBufferFull {
+			reader.pos += int64(len(skipped))
+			skipped, err = reader.reader.ReadSlice('\n')
+		}
+		reader.pos += int64(len(skipped))
+		if err != nil && err != io.EOF {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %v", r.File, err)
+		}
+	}
+	return reader, nil
+}
+
+// rangeLineReader passes on whole lines for as long as they start before
+// end.
+type rangeLineReader struct {
+	file    io.Closer
+	reader  *bufio.Reader
+	pos     int64 // File offset of the next unread byte
+	end     int64
+	inLine  bool // The last byte passed on was not a newline
+	pending []byte
+}
+
+func (r *rangeLineReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		// A new line may only begin inside the range
+		if !r.inLine && r.pos >= r.end {
+			return 0, io.EOF
+		}
+		chunk, err := r.reader.ReadSlice('\n')
+		if len(chunk) == 0 {
+			return 0, err
+		}
+		r.pos += int64(len(chunk))
+		r.inLine = chunk[len(chunk)-1] != '\n'
+		r.pending = chunk
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *rangeLineReader) Close() error {
+	return r.file.Close()
+}
+
+// splitRanges cuts a file of backend into ranges of about chunkSize bytes that end
+// at record boundaries of format. A record longer than chunkSize gets a range
+// of its own rather than growing the range of the records before it. Newline
+// delimited formats only read the record around each cut.
+func splitRanges(backend Backend, path string, size int64, chunkSize int, format InputFormat) ([]InputRange, error) {
+	file, err := openSeeker(backend, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var ranges []InputRange
+	for offset := int64(0); offset < size; {
+		end := offset + int64(chunkSize)
+		if end >= size {
+			end = size
+		} else {
+			// Move the cut to the end of the record holding its last byte,
+			// or to its start if that record alone is over chunkSize
+			recordStart, next, err := format.Align(file, offset, end)
+			if err != nil {
+				return nil, err
+			}
+			end = min(next, size)
+			if recordStart > offset && end-recordStart > int64(chunkSize) {
+				end = recordStart
+			}
+		}
+
+		ranges = append(ranges, InputRange{File: path, Offset: offset, Length: end - offset})
+		offset = end
+	}
+	return ranges, nil
+}
+
+// maxSkip is how far backendSeeker reads ahead to reach a later offset
+// rather than opening the file again.
+const maxSkip = 64 << 10
+
+// openSeeker opens a file of backend for reading at any offset, as aligning
+// splits needs. Files that can't seek themselves are opened again at the
+// offset seeked to.
+func openSeeker(backend Backend, name string) (io.ReadSeekCloser, error) {
+	file, err := backend.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if seeker, ok := file.(io.ReadSeekCloser); ok {
+		return seeker, nil
+	}
+	return &backendSeeker{backend: backend, name: name, file: file}, nil
+}
+
+// backendSeeker reads a file through Backend.OpenAt, reopening it when it is
+// seeked backwards or far ahead.
+type backendSeeker struct {
+	backend Backend
+	name    string
+	file    io.ReadCloser // Open at pos, nil until the next Read opens it
+	pos     int64
+}
+
+func (s *backendSeeker) Read(p []byte) (int, error) {
+	if s.file == nil {
+		file, err := s.backend.OpenAt(s.name, s.pos)
+		if err != nil {
+			return 0, err
+		}
+		s.file = file
+	}
+	n, err := s.file.Read(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *backendSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	default:
+		return 0, fmt.Errorf("failed to seek %s: unsupported whence %d", s.name, whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("failed to seek %s: negative offset %d", s.name, offset)
+	}
+
+	if s.file != nil && offset >= s.pos && offset-s.pos <= maxSkip {
+		n, err := io.CopyN(io.Discard, s.file, offset-s.pos)
+		s.pos += n
+		if err == nil || err == io.EOF {
+			s.pos = offset
+			return offset, nil
+		}
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+func (s *backendSeeker) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// binaryRecords encodes payloads as length-prefixed binary records.
+func binaryRecords(payloads ...string) string {
+	var b strings.Builder
+	for _, p := range payloads {
+		var header [4]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(p)))
+		b.Write(header[:])
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+func TestSplitRangesGivesLongRecordItsOwnRange(t *testing.T) {
+	long := strings.Repeat("L", 100)
+	tests := []struct {
+		format  InputFormat
+		content string
+		want    [][2]int64 // offset, length
+	}{
+		{LineFormat{}, "a\n" + long + "\nb\nc\n", [][2]int64{{0, 2}, {2, 101}, {103, 4}}},
+		{JSONLinesFormat{}, "1\n\"" + long + "\"\n2\n", [][2]int64{{0, 2}, {2, 103}, {105, 2}}},
+		{CSVFormat{}, "a\n\"" + long + "\n" + long + "\"\nb\n", [][2]int64{{0, 2}, {2, 204}, {206, 2}}},
+		{BinaryFormat{}, binaryRecords("a", long, "b"), [][2]int64{{0, 5}, {5, 104}, {109, 5}}},
+		// Short records still fill a range up to the record holding the cut
+		{LineFormat{}, "aaaa\nbbbbbb\ncc\n", [][2]int64{{0, 12}, {12, 3}}},
+	}
+	for _, tt := range tests {
+		// The local backend seeks the file itself, the memory backend is
+		// read through backendSeeker
+		for _, backend := range []Backend{NewLocalBackend(), NewMemoryBackend()} {
+			path := filepath.Join(t.TempDir(), "input")
+			writeFile(t, backend, path, tt.content)
+			ranges, err := splitRanges(backend, path, int64(len(tt.content)), 10, tt.format)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.format.Name(), err)
+			}
+			var got [][2]int64
+			for _, r := range ranges {
+				got = append(got, [2]int64{r.Offset, r.Length})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("%s %T: split %q into %v, want %v", tt.format.Name(), backend, tt.content, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("%s %T: split %q into %v, want %v", tt.format.Name(), backend, tt.content, got, tt.want)
+				}
+			}
+		}
+	}
+}
+
+// rangeRecords reads the records of ranges, one range after the other.
+func rangeRecords(t *testing.T, backend Backend, ranges []InputRange, format InputFormat) []string {
+	t.Helper()
+	var records []string
+	for _, r := range ranges {
+		reader, file, err := OpenRecords(backend, r, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("range %+v: %v", r, err)
+			}
+			records = append(records, string(record))
+		}
+		file.Close()
+	}
+	return records
+}
+
+// cutRanges cuts [0, size) into ranges at the given offsets.
+func cutRanges(name string, size int64, cuts ...int64) []InputRange {
+	var ranges []InputRange
+	offset := int64(0)
+	for _, cut := range append(cuts, size) {
+		ranges = append(ranges, InputRange{File: name, Offset: offset, Length: cut - offset})
+		offset = cut
+	}
+	return ranges
+}
+
+func TestInputRangesReadEveryLineOnce(t *testing.T) {
+	backend := NewMemoryBackend()
+	for _, content := range []string{
+		"alpha\nbravo charlie\n\ndelta\n",
+		"alpha\nbravo charlie\n\ndelta", // No trailing newline
+	} {
+		writeFile(t, backend, "input.txt", content)
+		want := fmt.Sprint(rangeRecords(t, backend, []InputRange{WholeFile("input.txt")}, LineFormat{}))
+		size := int64(len(content))
+
+		// Every pair of cuts, which covers ranges starting inside a line,
+		// right after a newline and on a newline, as well as empty ranges
+		// and an empty final range
+		for first := int64(0); first <= size; first++ {
+			for second := first; second <= size; second++ {
+				ranges := cutRanges("input.txt", size, first, second)
+				if got := fmt.Sprint(rangeRecords(t, backend, ranges, LineFormat{})); got != want {
+					t.Fatalf("%q cut at %d and %d read %s, want %s", content, first, second, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestInputRangeStartingOnNewline(t *testing.T) {
+	backend := NewMemoryBackend()
+	writeFile(t, backend, "input.txt", "one\ntwo\nthree\n")
+
+	// Byte 3 is the newline ending "one", so the range starts with "two"
+	got := rangeRecords(t, backend, []InputRange{{File: "input.txt", Offset: 3, Length: 5}}, LineFormat{})
+	if fmt.Sprint(got) != "[two]" {
+		t.Fatalf("range from the newline read %v, want [two]", got)
+	}
+	// A range starting right after a newline keeps its first line
+	got = rangeRecords(t, backend, []InputRange{{File: "input.txt", Offset: 4, Length: 1}}, LineFormat{})
+	if fmt.Sprint(got) != "[two]" {
+		t.Fatalf("range after the newline read %v, want [two]", got)
+	}
+	// An empty range at the end of the file reads nothing
+	got = rangeRecords(t, backend, []InputRange{{File: "input.txt", Offset: 14, Length: 0}}, LineFormat{})
+	if len(got) != 0 {
+		t.Fatalf("empty final range read %v", got)
+	}
+}
+
+func TestSplitRangesAlignCSVAndJSONLines(t *testing.T) {
+	tests := []struct {
+		format  InputFormat
+		content string
+	}{
+		{CSVFormat{}, "id,text\r\n1,\"two\nlines\"\r\n2,\"\"\"quoted\"\", \n\n still\"\n3,plain\n4,\"last\nrecord\""},
+		{JSONLinesFormat{}, "{\"a\": 1}\n\n{\"b\": \"x\\ny\"}\n[1, 2, 3]\n\"tail\""},
+	}
+	for _, tt := range tests {
+		backend := NewMemoryBackend()
+		writeFile(t, backend, "input", tt.content)
+		size := int64(len(tt.content))
+		want := fmt.Sprintf("%q", rangeRecords(t, backend, []InputRange{WholeFile("input")}, tt.format))
+
+		for chunkSize := 1; chunkSize <= len(tt.content); chunkSize++ {
+			ranges, err := splitRanges(backend, "input", size, chunkSize, tt.format)
+			if err != nil {
+				t.Fatalf("%s, chunk size %d: %v", tt.format.Name(), chunkSize, err)
+			}
+			if got := fmt.Sprintf("%q", rangeRecords(t, backend, ranges, tt.format)); got != want {
+				t.Fatalf("%s, chunk size %d: read %s from %v, want %s", tt.format.Name(), chunkSize, got, ranges, want)
+			}
+		}
+	}
+}
+
+func TestLongLinesAcrossInputRanges(t *testing.T) {
+	backend := NewMemoryBackend()
+	// Lines longer than the reader's buffer, so skipping the line a range
+	// starts in and reading past the range end both take several reads
+	long := strings.Repeat("x", 10000)
+	content := "short\n" + long + "\n" + long + "y\nend"
+	writeFile(t, backend, "input.txt", content)
+	size := int64(len(content))
+	want := fmt.Sprint([]string{"short", long, long + "y", "end"})
+
+	for _, chunkSize := range []int64{7, 1000, 4096, 9999, 10006, 10007} {
+		var cuts []int64
+		for cut := chunkSize; cut < size; cut += chunkSize {
+			cuts = append(cuts, cut)
+		}
+		if got := fmt.Sprint(rangeRecords(t, backend, cutRanges("input.txt", size, cuts...), LineFormat{})); got != want {
+			t.Fatalf("ranges of %d bytes read %d bytes of records, want the %d bytes of the 4 lines", chunkSize, len(got), len(want))
+		}
+	}
+
+	// The splitter gives each long line a range of its own
+	ranges, err := splitRanges(backend, "input.txt", size, 4096, LineFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 4 {
+		t.Fatalf("split into %v, want one range per line", ranges)
+	}
+	if got := fmt.Sprint(rangeRecords(t, backend, ranges, LineFormat{})); got != want {
+		t.Fatalf("split ranges read %d bytes of records, want %d", len(got), len(want))
+	}
+}
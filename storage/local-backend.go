@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LocalBackend stores files on the local filesystem. Nodes on different hosts
+// need a shared mount to see each other's files.
+type LocalBackend struct{}
+
+// NewLocalBackend creates a backend for the local filesystem.
+func NewLocalBackend() *LocalBackend {
+	return &LocalBackend{}
+}
+
+func (b *LocalBackend) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (b *LocalBackend) OpenAt(name string, offset int64) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Create writes to a temporary file next to name and renames it into place
+// on Close.
+func (b *LocalBackend) Create(name string) (Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-"+filepath.Base(name)+"-*")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: tmp, name: name}, nil
+}
+
+func (b *LocalBackend) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		names = append(names, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *LocalBackend) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (b *LocalBackend) Stat(name string) (FileInfo, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}, nil
+}
+
+type localWriter struct {
+	*os.File
+	name string
+}
+
+func (w *localWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	if err := os.Rename(w.File.Name(), w.name); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	return nil
+}
+
+func (w *localWriter) Abort() error {
+	w.File.Close()
+	return os.Remove(w.File.Name())
+}
+
+// WriteFileAtomic writes data to a temporary file next to path on the local
+// filesystem and renames it into place, so readers and a crash midway see
+// either the old or the new content. The file is synced before the rename
+// and the directory after it, so the new content survives a power loss once
+// this returns.
+func WriteFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes a directory entry change, such as a rename, to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
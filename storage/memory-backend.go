@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps files in memory. It is meant for tests that run every
+// node in one process.
+type MemoryBackend struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemoryBackend creates an empty in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{files: make(map[string]memoryFile)}
+}
+
+func (b *MemoryBackend) Open(name string) (io.ReadCloser, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	f, ok := b.files[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+func (b *MemoryBackend) OpenAt(name string, offset int64) (io.ReadCloser, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	f, ok := b.files[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if offset < 0 || offset > int64(len(f.data)) {
+		return nil, fmt.Errorf("offset %d out of range for %s", offset, name)
+	}
+	return io.NopCloser(bytes.NewReader(f.data[offset:])), nil
+}
+
+func (b *MemoryBackend) Create(name string) (Writer, error) {
+	return &memoryWriter{backend: b, name: path.Clean(name)}, nil
+}
+
+func (b *MemoryBackend) List(dir string) ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	prefix := strings.TrimSuffix(path.Clean(dir), "/") + "/"
+	var names []string
+	for name := range b.files {
+		if rest, ok := strings.CutPrefix(name, prefix); ok && !strings.Contains(rest, "/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *MemoryBackend) Remove(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	name = path.Clean(name)
+	if _, ok := b.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(b.files, name)
+	return nil
+}
+
+func (b *MemoryBackend) Stat(name string) (FileInfo, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	f, ok := b.files[path.Clean(name)]
+	if !ok {
+		return FileInfo{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return FileInfo{Name: name, Size: int64(len(f.data)), ModTime: f.modTime}, nil
+}
+
+type memoryWriter struct {
+	backend *MemoryBackend
+	name    string
+	buf     bytes.Buffer
+	done    bool
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, fmt.Errorf("write to closed file %s", w.name)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *memoryWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	w.backend.mu.Lock()
+	defer w.backend.mu.Unlock()
+	w.backend.files[w.name] = memoryFile{data: w.buf.Bytes(), modTime: time.Now()}
+	return nil
+}
+
+func (w *memoryWriter) Abort() error {
+	w.done = true
+	w.buf.Reset()
+	return nil
+}
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config describes an S3 compatible bucket.
+type S3Config struct {
+	Endpoint  string // e.g. http://localhost:9000
+	Region    string // defaults to us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Backend stores files as objects in an S3 compatible bucket using path
+// style requests signed with AWS Signature Version 4, which is what MinIO and
+// most self-hosted object stores expect. File names map to object keys with
+// the leading slash removed.
+type S3Backend struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Backend creates a backend for the bucket described by config.
+func NewS3Backend(config S3Config) (*S3Backend, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 backend requires a bucket")
+	}
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("s3 backend requires an endpoint")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: %v", config.Endpoint, err)
+	}
+
+	return &S3Backend{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (b *S3Backend) Open(name string) (io.ReadCloser, error) {
+	return b.OpenAt(name, 0)
+}
+
+// OpenAt requests the object from offset on with a Range header.
+func (b *S3Backend) OpenAt(name string, offset int64) (io.ReadCloser, error) {
+	var header http.Header
+	if offset > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}}
+	}
+	resp, err := b.do(http.MethodGet, objectKey(name), nil, header, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return nil, responseError("open", name, resp)
+	}
+	return resp.Body, nil
+}
+
+// Create spools the data to a local temporary file and uploads it on Close,
+// so the object only appears once it is complete.
+func (b *S3Backend) Create(name string) (Writer, error) {
+	spool, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload spool: %v", err)
+	}
+	return &s3Writer{File: spool, backend: b, name: name}, nil
+}
+
+func (b *S3Backend) List(dir string) ([]string, error) {
+	prefix := objectKey(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var names []string
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		query.Set("delimiter", "/")
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := b.do(http.MethodGet, "", query, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, responseError("list", dir, resp)
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode listing of %s: %v", dir, err)
+		}
+
+		for _, c := range result.Contents {
+			names = append(names, path.Join(dir, strings.TrimPrefix(c.Key, prefix)))
+		}
+		if !result.IsTruncated {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (b *S3Backend) Remove(name string) error {
+	resp, err := b.do(http.MethodDelete, objectKey(name), nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return responseError("remove", name, resp)
+	}
+	return nil
+}
+
+func (b *S3Backend) Stat(name string) (FileInfo, error) {
+	resp, err := b.do(http.MethodHead, objectKey(name), nil, nil, nil, 0)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return FileInfo{}, responseError("stat", name, resp)
+	}
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return FileInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// do sends a signed request for key, or for the bucket itself when key is
+// empty, with the extra headers given.
+func (b *S3Backend) do(method, key string, query url.Values, header http.Header, body io.Reader, length int64) (*http.Response, error) {
+	u := *b.endpoint
+	u.Path = "/" + b.config.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = encodeQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	signRequest(req, b.config, "UNSIGNED-PAYLOAD", time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s failed: %v", method, key, err)
+	}
+	return resp, nil
+}
+
+// objectKey turns a file name into an object key.
+func objectKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// responseError converts an unexpected S3 response into an error. Missing
+// objects wrap fs.ErrNotExist like the other backends.
+func responseError(op, name string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s: %s: %s", op, name, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+type s3Writer struct {
+	*os.File
+	backend *S3Backend
+	name    string
+}
+
+func (w *s3Writer) Close() error {
+	defer os.Remove(w.File.Name())
+	defer w.File.Close()
+
+	size, err := w.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := w.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	resp, err := w.backend.do(http.MethodPut, objectKey(w.name), nil, nil, w.File, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("create", w.name, resp)
+	}
+	return nil
+}
+
+func (w *s3Writer) Abort() error {
+	w.File.Close()
+	return os.Remove(w.File.Name())
+}
+
+// signRequest adds an AWS Signature Version 4 Authorization header to req.
+// The host, Content-Type, Range and x-amz-* headers are signed.
+func signRequest(req *http.Request, config S3Config, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		encodeQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretKey), day)
+	key = hmacSHA256(key, config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// encodeQuery builds the canonical query string: keys sorted and every key
+// and value URI encoded.
+func encodeQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything except the unreserved characters, and
+// slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
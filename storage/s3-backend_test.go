@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand-in for an S3 compatible object store. It
+// checks the Signature Version 4 of every request, answers Range requests and
+// pages listings pageSize keys at a time.
+type fakeS3 struct {
+	bucket   string
+	config   S3Config
+	pageSize int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	pages   int // ListObjectsV2 responses served
+}
+
+// newFakeS3 starts a fake store and returns it with a backend talking to it.
+func newFakeS3(t *testing.T) (*fakeS3, *S3Backend) {
+	t.Helper()
+	f := &fakeS3{
+		bucket:   "test-bucket",
+		pageSize: 2,
+		objects:  make(map[string][]byte),
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	f.config = S3Config{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    f.bucket,
+		AccessKey: "AKIDTEST",
+		SecretKey: "secret/key+test",
+	}
+	backend, err := NewS3Backend(f.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, backend
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verifySignature(r); err != "" {
+		http.Error(w, "SignatureDoesNotMatch: "+err, http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket)
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(data)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = data
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, time.Unix(1700000000, 0), bytes.NewReader(data))
+	case r.Method == http.MethodDelete:
+		if _, ok := f.objects[key]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// list answers ListObjectsV2 with the keys directly under the prefix. The
+// continuation token is the index of the next key.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" || query.Get("delimiter") != "/" {
+		http.Error(w, "unexpected listing "+r.URL.RawQuery, http.StatusBadRequest)
+		return
+	}
+	prefix := query.Get("prefix")
+
+	var keys []string
+	for key := range f.objects {
+		if rest, ok := strings.CutPrefix(key, prefix); ok && !strings.Contains(rest, "/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := min(start+f.pageSize, len(keys))
+	f.pages++
+
+	type content struct {
+		Key string `xml:"Key"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}{IsTruncated: end < len(keys)}
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, content{Key: key})
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// verifySignature recomputes the signature of r from the headers it names and
+// returns what is wrong with it, or "" if it matches.
+func (f *fakeS3) verifySignature(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		if name, value, ok := strings.Cut(part, "="); ok {
+			fields[name] = value
+		}
+	}
+	amzDate := r.Header.Get("x-amz-date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return "missing x-amz-date"
+	}
+	scope := amzDate[:8] + "/" + f.config.Region + "/s3/aws4_request"
+	if fields["Credential"] != f.config.AccessKey+"/"+scope {
+		return "bad credential " + fields["Credential"]
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signed) {
+		return "signed headers not sorted"
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, name := range []string{"host", "x-amz-date", "x-amz-content-sha256"} {
+		if !strings.Contains(";"+fields["SignedHeaders"]+";", ";"+name+";") {
+			return name + " is not signed"
+		}
+	}
+	if r.Header.Get("Range") != "" && !strings.Contains(fields["SignedHeaders"], "range") {
+		return "range is not signed"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		encodeQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+f.config.SecretKey), amzDate[:8])
+	key = hmacSHA256(key, f.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	if want := hex.EncodeToString(hmacSHA256(key, stringToSign)); fields["Signature"] != want {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+func TestS3BackendRejectedSignature(t *testing.T) {
+	f, _ := newFakeS3(t)
+	config := f.config
+	config.SecretKey = "wrong"
+	backend, err := NewS3Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = backend.Stat("missing")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("stat with the wrong secret returned %v, want a 403 error", err)
+	}
+}
+
+func TestS3BackendListPages(t *testing.T) {
+	f, backend := newFakeS3(t)
+	var want []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		writeFile(t, backend, "dir/"+name, name)
+		want = append(want, "dir/"+name)
+	}
+
+	got, err := backend.List("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("List returned %v, want %v", got, want)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pages != 3 {
+		t.Fatalf("listing took %d pages, want 3", f.pages)
+	}
+}
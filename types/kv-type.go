@@ -15,6 +15,15 @@ type Mapper func(record string) []KeyValue
 // Reducer is a function type that takes a key and a slice of values,
 type Reducer func(key string, values []string) string
 
+// Combiner is the optional function a plugin exports as Combine. It merges
+// the values of one key on the map side into a single value, which the
+// Reducer must accept like any other value.
+type Combiner func(key string, values []string) string
+
+// Partitioner is the optional function a plugin exports as Partition. It
+// returns the reducer, from 0 to n-1, that receives key.
+type Partitioner func(key string, n int) int
+
 var ErrInvalidMapper = errors.New("invalid mapper: plugin symbol Map does not implement Mapper interface")
 
 var ErrInvalidReducer = errors.New("invalid reducer: plugin symbol Reduce does not implement Reducer interface")
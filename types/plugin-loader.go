@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// LookupSymbol opens the plugin at path and looks up name. Optional symbols
+// that the plugin doesn't export are returned as nil without an error.
+func LookupSymbol(path string, name string, optional bool) (plugin.Symbol, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(name)
+	if err != nil {
+		if optional {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return sym, nil
+}
+
+// LoadMapper looks up the Map symbol of the plugin at path.
+func LoadMapper(path string) (Mapper, error) {
+	sym, err := LookupSymbol(path, "Map", false)
+	if err != nil {
+		return nil, err
+	}
+	rawFunc, ok := sym.(func(string) []KeyValue)
+	if !ok {
+		return nil, fmt.Errorf("invalid mapper signature: %T", sym)
+	}
+	return Mapper(rawFunc), nil
+}
+
+// LoadReducer looks up the Reduce symbol of the plugin at path.
+func LoadReducer(path string) (Reducer, error) {
+	sym, err := LookupSymbol(path, "Reduce", false)
+	if err != nil {
+		return nil, err
+	}
+	rawFunc, ok := sym.(func(string, []string) string)
+	if !ok {
+		return nil, fmt.Errorf("invalid reducer signature: %T", sym)
+	}
+	return Reducer(rawFunc), nil
+}
+
+// LoadCombiner looks up the optional Combine symbol of the plugin at path. It
+// returns nil if the plugin has none.
+func LoadCombiner(path string) (Combiner, error) {
+	sym, err := LookupSymbol(path, "Combine", true)
+	if err != nil || sym == nil {
+		return nil, err
+	}
+	rawFunc, ok := sym.(func(string, []string) string)
+	if !ok {
+		return nil, fmt.Errorf("invalid combiner signature: %T", sym)
+	}
+	return Combiner(rawFunc), nil
+}
+
+// LoadPartitioner looks up the optional Partition symbol of the plugin at
+// path. It returns nil if the plugin has none.
+func LoadPartitioner(path string) (Partitioner, error) {
+	sym, err := LookupSymbol(path, "Partition", true)
+	if err != nil || sym == nil {
+		return nil, err
+	}
+	rawFunc, ok := sym.(func(string, int) int)
+	if !ok {
+		return nil, fmt.Errorf("invalid partitioner signature: %T", sym)
+	}
+	return Partitioner(rawFunc), nil
+}
@@ -0,0 +1,418 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"go-mr/storage"
+)
+
+// Map output is stored in one intermediate file per map task, laid out as
+//
+//	header     magic "MRKV", version byte, uvarint length and ID of the map task
+//	partitions the blocks of partition 0, then of partition 1, ...
+//	index      per partition a big-endian uint64 offset and uint64 length
+//	footer     uint64 index offset, uint32 CRC32C of the index, magic "MRKV"
+//
+// Every block is a big-endian uint32 payload length, a uint32 CRC32C of the
+// payload and the payload, which holds whole records. A record is the
+// uvarint length of the key, the key, the uvarint length of the value and
+// the value, so keys and values may hold any bytes. Records of a partition
+// are sorted by key.
+const (
+	intermediateMagic     = "MRKV"
+	intermediateVersion   = 1
+	intermediateBlockSize = 64 * 1024 // Payload bytes after which a block is closed
+	intermediateFooterLen = 8 + 4 + len(intermediateMagic)
+	blockHeaderLen        = 4 + 4
+	indexEntryLen         = 8 + 8
+)
+
+// ErrCorruptIntermediate is wrapped by the errors of intermediate files that
+// fail their checksums or don't follow the format.
+var ErrCorruptIntermediate = errors.New("corrupt intermediate file")
+
+// partitionSection locates the blocks of one partition in an intermediate
+// file.
+type partitionSection struct {
+	offset int64
+	length int64
+}
+
+// intermediateWriter writes the records of a map task, partition by
+// partition, in the intermediate file format.
+type intermediateWriter struct {
+	writer    *bufio.Writer
+	offset    int64
+	sections  []partitionSection
+	partition int // Partition of the records in block
+	block     []byte
+}
+
+// newIntermediateWriter writes the header of the intermediate file of taskID
+// with nPartitions partitions to w.
+func newIntermediateWriter(w io.Writer, taskID string, nPartitions int) (*intermediateWriter, error) {
+	iw := &intermediateWriter{
+		writer:   bufio.NewWriter(w),
+		sections: make([]partitionSection, nPartitions),
+	}
+	header := append([]byte(intermediateMagic), intermediateVersion)
+	header = binary.AppendUvarint(header, uint64(len(taskID)))
+	header = append(header, taskID...)
+	if err := iw.write(header); err != nil {
+		return nil, err
+	}
+	for i := range iw.sections {
+		iw.sections[i].offset = iw.offset
+	}
+	return iw, nil
+}
+
+func (iw *intermediateWriter) write(data []byte) error {
+	n, err := iw.writer.Write(data)
+	iw.offset += int64(n)
+	return err
+}
+
+// Write appends a record to partition. Partitions must be written in
+// increasing order and the records of each sorted by key.
+func (iw *intermediateWriter) Write(partition int, key, value string) error {
+	if partition < iw.partition || partition >= len(iw.sections) {
+		return fmt.Errorf("record for partition %d written after partition %d of %d", partition, iw.partition, len(iw.sections))
+	}
+	if partition != iw.partition {
+		if err := iw.flushBlock(); err != nil {
+			return err
+		}
+		iw.startPartition(partition)
+	}
+
+	iw.block = binary.AppendUvarint(iw.block, uint64(len(key)))
+	iw.block = append(iw.block, key...)
+	iw.block = binary.AppendUvarint(iw.block, uint64(len(value)))
+	iw.block = append(iw.block, value...)
+	if len(iw.block) >= intermediateBlockSize {
+		return iw.flushBlock()
+	}
+	return nil
+}
+
+// startPartition moves on to partition, leaving the partitions in between
+// empty.
+func (iw *intermediateWriter) startPartition(partition int) {
+	for p := iw.partition + 1; p <= partition; p++ {
+		iw.sections[p].offset = iw.offset
+	}
+	iw.partition = partition
+}
+
+// flushBlock writes the buffered records as one block of the current
+// partition.
+func (iw *intermediateWriter) flushBlock() error {
+	if len(iw.block) == 0 {
+		return nil
+	}
+	var header [blockHeaderLen]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(iw.block)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.Checksum(iw.block, castagnoli))
+	if err := iw.write(header[:]); err != nil {
+		return err
+	}
+	if err := iw.write(iw.block); err != nil {
+		return err
+	}
+	iw.sections[iw.partition].length = iw.offset - iw.sections[iw.partition].offset
+	iw.block = iw.block[:0]
+	return nil
+}
+
+// Close writes the last block, the index and the footer. It doesn't close
+// the underlying writer.
+func (iw *intermediateWriter) Close() error {
+	if err := iw.flushBlock(); err != nil {
+		return err
+	}
+	iw.startPartition(len(iw.sections) - 1)
+	return iw.writeIndex()
+}
+
+// writeIndex writes the partition index and footer.
+func (iw *intermediateWriter) writeIndex() error {
+	indexOffset := iw.offset
+	index := make([]byte, 0, len(iw.sections)*indexEntryLen)
+	for _, s := range iw.sections {
+		index = binary.BigEndian.AppendUint64(index, uint64(s.offset))
+		index = binary.BigEndian.AppendUint64(index, uint64(s.length))
+	}
+	footer := binary.BigEndian.AppendUint64(nil, uint64(indexOffset))
+	footer = binary.BigEndian.AppendUint32(footer, crc32.Checksum(index, castagnoli))
+	footer = append(footer, intermediateMagic...)
+	if err := iw.write(index); err != nil {
+		return err
+	}
+	if err := iw.write(footer); err != nil {
+		return err
+	}
+	return iw.writer.Flush()
+}
+
+// intermediateFile is the header and index of an intermediate file.
+type intermediateFile struct {
+	name     string
+	taskID   string // Map task that wrote the file
+	sections []partitionSection
+}
+
+// openIntermediateFile reads the header and partition index of an
+// intermediate file.
+func openIntermediateFile(backend storage.Backend, name string) (*intermediateFile, error) {
+	info, err := backend.Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat intermediate file: %v", err)
+	}
+
+	f := &intermediateFile{name: name}
+	if err := f.readHeader(backend); err != nil {
+		return nil, err
+	}
+	if info.Size < int64(intermediateFooterLen) {
+		return nil, f.corrupt("file is too short for its footer")
+	}
+	footer, err := readAt(backend, name, info.Size-int64(intermediateFooterLen), intermediateFooterLen)
+	if err != nil {
+		return nil, err
+	}
+	if string(footer[12:]) != intermediateMagic {
+		return nil, f.corrupt("footer magic %q, want %q", footer[12:], intermediateMagic)
+	}
+	indexOffset := int64(binary.BigEndian.Uint64(footer[0:8]))
+	indexLen := info.Size - int64(intermediateFooterLen) - indexOffset
+	if indexOffset < 0 || indexLen < 0 || indexLen%indexEntryLen != 0 {
+		return nil, f.corrupt("index at offset %d doesn't fit a file of %d bytes", indexOffset, info.Size)
+	}
+
+	index, err := readAt(backend, name, indexOffset, int(indexLen))
+	if err != nil {
+		return nil, err
+	}
+	if sum, want := crc32.Checksum(index, castagnoli), binary.BigEndian.Uint32(footer[8:12]); sum != want {
+		return nil, f.corrupt("partition index checksum %08x, want %08x", sum, want)
+	}
+	for i := 0; i < len(index); i += indexEntryLen {
+		s := partitionSection{
+			offset: int64(binary.BigEndian.Uint64(index[i:])),
+			length: int64(binary.BigEndian.Uint64(index[i+8:])),
+		}
+		if s.offset < 0 || s.length < 0 || s.offset+s.length > indexOffset {
+			return nil, f.corrupt("partition %d at offset %d with %d bytes is out of bounds", len(f.sections), s.offset, s.length)
+		}
+		f.sections = append(f.sections, s)
+	}
+	return f, nil
+}
+
+// readHeader reads the magic, version and map task ID at the start of the
+// file.
+func (f *intermediateFile) readHeader(backend storage.Backend) error {
+	file, err := backend.Open(f.name)
+	if err != nil {
+		return fmt.Errorf("failed to open intermediate file: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(intermediateMagic)+1)
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return f.corrupt("failed to read header: %v", err)
+	}
+	if string(magic[:len(intermediateMagic)]) != intermediateMagic {
+		return f.corrupt("header magic %q, want %q", magic[:len(intermediateMagic)], intermediateMagic)
+	}
+	if version := magic[len(intermediateMagic)]; version != intermediateVersion {
+		return fmt.Errorf("%s has intermediate format version %d, this worker reads version %d", f.name, version, intermediateVersion)
+	}
+	n, err := binary.ReadUvarint(reader)
+	if err != nil || n > 1<<16 {
+		return f.corrupt("invalid map task ID length")
+	}
+	taskID := make([]byte, n)
+	if _, err := io.ReadFull(reader, taskID); err != nil {
+		return f.corrupt("failed to read map task ID: %v", err)
+	}
+	f.taskID = string(taskID)
+	return nil
+}
+
+// corrupt returns an ErrCorruptIntermediate error about the file.
+func (f *intermediateFile) corrupt(format string, args ...any) error {
+	who := f.name
+	if f.taskID != "" {
+		who = fmt.Sprintf("%s of map task %s", f.name, f.taskID)
+	}
+	return fmt.Errorf("%w %s: %s", ErrCorruptIntermediate, who, fmt.Sprintf(format, args...))
+}
+
+// readAt reads n bytes of a file starting at offset.
+func readAt(backend storage.Backend, name string, offset int64, n int) ([]byte, error) {
+	file, err := backend.OpenAt(name, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open intermediate file: %v", err)
+	}
+	defer file.Close()
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, fmt.Errorf("failed to read intermediate file %s: %v", name, err)
+	}
+	return data, nil
+}
+
+// partitionReader streams the records of one partition of an intermediate
+// file, verifying every block against its checksum before using it.
+type partitionReader struct {
+	file      *intermediateFile
+	partition int
+	body      io.ReadCloser
+	reader    *bufio.Reader
+	blocks    int // Blocks read so far
+	block     []byte
+	pos       int // Read position in block
+}
+
+// openPartition opens one partition of an intermediate file. A file written
+// for fewer partitions has no records for the missing ones.
+func openPartition(backend storage.Backend, name string, partition int) (*partitionReader, error) {
+	f, err := openIntermediateFile(backend, name)
+	if err != nil {
+		return nil, err
+	}
+	return f.openPartition(backend, partition)
+}
+
+func (f *intermediateFile) openPartition(backend storage.Backend, partition int) (*partitionReader, error) {
+	r := &partitionReader{file: f, partition: partition}
+	if partition >= len(f.sections) || f.sections[partition].length == 0 {
+		return r, nil
+	}
+
+	section := f.sections[partition]
+	body, err := backend.OpenAt(f.name, section.offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open intermediate file: %v", err)
+	}
+	r.body = body
+	r.reader = bufio.NewReader(io.LimitReader(body, section.length))
+	return r, nil
+}
+
+// Next returns the next record of the partition, or false at its end.
+func (r *partitionReader) Next() (KeyValue, bool, error) {
+	if r.pos == len(r.block) {
+		ok, err := r.nextBlock()
+		if !ok || err != nil {
+			return KeyValue{}, false, err
+		}
+	}
+
+	key, err := r.field()
+	if err != nil {
+		return KeyValue{}, false, err
+	}
+	value, err := r.field()
+	if err != nil {
+		return KeyValue{}, false, err
+	}
+	return KeyValue{Key: key, Value: value}, true, nil
+}
+
+// field decodes one length-prefixed field of the current block.
+func (r *partitionReader) field() (string, error) {
+	n, size := binary.Uvarint(r.block[r.pos:])
+	if size <= 0 || n > uint64(len(r.block)-r.pos-size) {
+		return "", r.corrupt(r.blocks-1, "record at byte %d overruns the block", r.pos)
+	}
+	r.pos += size
+	field := string(r.block[r.pos : r.pos+int(n)])
+	r.pos += int(n)
+	return field, nil
+}
+
+// nextBlock reads and verifies the next block of the partition.
+func (r *partitionReader) nextBlock() (bool, error) {
+	if r.reader == nil {
+		return false, nil
+	}
+	var header [blockHeaderLen]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, r.corrupt(r.blocks, "truncated block header: %v", err)
+	}
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	want := binary.BigEndian.Uint32(header[4:8])
+
+	var payload bytes.Buffer
+	if _, err := io.CopyN(&payload, r.reader, size); err != nil {
+		return false, r.corrupt(r.blocks, "truncated block of %d bytes: %v", size, err)
+	}
+	if sum := crc32.Checksum(payload.Bytes(), castagnoli); sum != want {
+		return false, r.corrupt(r.blocks, "checksum %08x, want %08x", sum, want)
+	}
+
+	r.block = payload.Bytes()
+	r.pos = 0
+	r.blocks++
+	return true, nil
+}
+
+// corrupt returns an error naming the map task, partition and block that
+// failed.
+func (r *partitionReader) corrupt(block int, format string, args ...any) error {
+	return r.file.corrupt("block %d of partition %d: %s", block, r.partition, fmt.Sprintf(format, args...))
+}
+
+// Close closes the partition.
+func (r *partitionReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// copyPartition writes one partition of an intermediate file to w as an
+// intermediate file of its own, so a reducer reads fetched partitions like
+// any other intermediate file. Blocks are copied without decoding them; the
+// reducer verifies their checksums.
+func copyPartition(w io.Writer, backend storage.Backend, name string, partition int) error {
+	f, err := openIntermediateFile(backend, name)
+	if err != nil {
+		return err
+	}
+
+	iw, err := newIntermediateWriter(w, f.taskID, partition+1)
+	if err != nil {
+		return err
+	}
+	if partition < len(f.sections) && f.sections[partition].length > 0 {
+		section := f.sections[partition]
+		body, err := backend.OpenAt(name, section.offset)
+		if err != nil {
+			return fmt.Errorf("failed to open intermediate file: %v", err)
+		}
+		defer body.Close()
+
+		n, err := io.CopyN(iw.writer, body, section.length)
+		iw.offset += n
+		if err != nil {
+			return fmt.Errorf("failed to read intermediate file %s: %v", name, err)
+		}
+		iw.sections[partition].length = section.length
+	}
+	return iw.writeIndex()
+}
@@ -0,0 +1,320 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"go-mr/storage"
+)
+
+// testRecords returns the records written to the test intermediate file:
+// partition 0 holds keys with arbitrary bytes, partition 1 is empty and
+// partition 2 is large enough to span several blocks.
+func testRecords() [][]KeyValue {
+	records := [][]KeyValue{
+		{{Key: "", Value: "empty key"}, {Key: "a\x00b", Value: "line\nbreak"}, {Key: "a\x00b", Value: ""}},
+		nil,
+		nil,
+	}
+	value := strings.Repeat("v", 1000)
+	for i := 0; i < 200; i++ {
+		records[2] = append(records[2], KeyValue{Key: fmt.Sprintf("key-%04d", i), Value: value})
+	}
+	return records
+}
+
+// writeTestIntermediate writes records, indexed by partition, as the
+// intermediate file of taskID.
+func writeTestIntermediate(t *testing.T, backend storage.Backend, name, taskID string, records [][]KeyValue) {
+	t.Helper()
+	file, err := backend.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := newIntermediateWriter(file, taskID, len(records))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, kvs := range records {
+		for _, kv := range kvs {
+			if err := writer.Write(p, kv.Key, kv.Value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestPartition returns every record of one partition.
+func readTestPartition(backend storage.Backend, name string, partition int) ([]KeyValue, error) {
+	reader, err := openPartition(backend, name, partition)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var records []KeyValue
+	for {
+		kv, ok, err := reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return records, nil
+		}
+		records = append(records, kv)
+	}
+}
+
+// fileBytes returns the content of a stored file.
+func fileBytes(t *testing.T, backend storage.Backend, name string) []byte {
+	t.Helper()
+	r, err := backend.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// storeBytes replaces the content of a stored file.
+func storeBytes(t *testing.T, backend storage.Backend, name string, data []byte) {
+	t.Helper()
+	w, err := backend.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func equalRecords(a, b []KeyValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIntermediateFileRoundTrip(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	records := testRecords()
+	writeTestIntermediate(t, backend, "mr-map-7", "map-7", records)
+
+	data := fileBytes(t, backend, "mr-map-7")
+	if !bytes.HasPrefix(data, []byte("MRKV\x01\x05map-7")) {
+		t.Fatalf("file starts with %q, want the MRKV header of map-7", data[:12])
+	}
+	if !bytes.HasSuffix(data, []byte(intermediateMagic)) {
+		t.Fatalf("file ends with %q, want the footer magic", data[len(data)-4:])
+	}
+
+	f, err := openIntermediateFile(backend, "mr-map-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.taskID != "map-7" || len(f.sections) != 3 {
+		t.Fatalf("read task %q with %d partitions, want map-7 with 3", f.taskID, len(f.sections))
+	}
+	if f.sections[1].length != 0 {
+		t.Fatalf("empty partition 1 has %d bytes", f.sections[1].length)
+	}
+	if f.sections[2].length <= intermediateBlockSize {
+		t.Fatalf("partition 2 has %d bytes, want more than one block", f.sections[2].length)
+	}
+
+	for p, want := range records {
+		got, err := readTestPartition(backend, "mr-map-7", p)
+		if err != nil {
+			t.Fatalf("partition %d: %v", p, err)
+		}
+		if !equalRecords(got, want) {
+			t.Fatalf("partition %d has %d records, want %d", p, len(got), len(want))
+		}
+	}
+
+	// A file written for fewer reducers has nothing for the others
+	if got, err := readTestPartition(backend, "mr-map-7", 5); err != nil || len(got) != 0 {
+		t.Fatalf("partition 5 has %d records, err %v, want none", len(got), err)
+	}
+}
+
+func TestIntermediateWriterRejectsPartitionsOutOfOrder(t *testing.T) {
+	writer, err := newIntermediateWriter(io.Discard, "map-0", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(2, "b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(1, "a", "1"); err == nil {
+		t.Fatal("record for an earlier partition was accepted")
+	}
+	if err := writer.Write(3, "c", "1"); err == nil {
+		t.Fatal("record for a partition past the last one was accepted")
+	}
+}
+
+func TestIntermediateFileCorruption(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	writeTestIntermediate(t, backend, "mr-map-7", "map-7", testRecords())
+	original := fileBytes(t, backend, "mr-map-7")
+	f, err := openIntermediateFile(backend, "mr-map-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstBlock := f.sections[2].offset
+	secondBlock := firstBlock + blockHeaderLen + int64(binary.BigEndian.Uint32(original[firstBlock:]))
+	indexOffset := int64(binary.BigEndian.Uint64(original[len(original)-intermediateFooterLen:]))
+
+	tests := []struct {
+		name      string
+		corrupt   func(data []byte) []byte
+		partition int
+		want      []string // Parts of the error
+	}{
+		{
+			name:      "payload of the first block",
+			corrupt:   func(data []byte) []byte { data[firstBlock+blockHeaderLen+10] ^= 0xff; return data },
+			partition: 2,
+			want:      []string{"mr-map-7", "map task map-7", "block 0 of partition 2", "checksum"},
+		},
+		{
+			name:      "checksum of the second block",
+			corrupt:   func(data []byte) []byte { data[secondBlock+4] ^= 0xff; return data },
+			partition: 2,
+			want:      []string{"mr-map-7", "map task map-7", "block 1 of partition 2", "checksum"},
+		},
+		{
+			name:      "partition index",
+			corrupt:   func(data []byte) []byte { data[indexOffset+3] ^= 0xff; return data },
+			partition: 0,
+			want:      []string{"mr-map-7", "map task map-7", "partition index checksum"},
+		},
+		{
+			name:      "footer magic",
+			corrupt:   func(data []byte) []byte { data[len(data)-1] = 'X'; return data },
+			partition: 0,
+			want:      []string{"mr-map-7", "footer magic"},
+		},
+		{
+			name:      "header magic",
+			corrupt:   func(data []byte) []byte { data[0] = 'X'; return data },
+			partition: 0,
+			want:      []string{"mr-map-7", "header magic"},
+		},
+		{
+			name:      "truncated file",
+			corrupt:   func(data []byte) []byte { return data[:firstBlock+100] },
+			partition: 2,
+			want:      []string{"mr-map-7", "map task map-7"},
+		},
+	}
+	for _, tt := range tests {
+		storeBytes(t, backend, "mr-map-7", tt.corrupt(bytes.Clone(original)))
+		_, err := readTestPartition(backend, "mr-map-7", tt.partition)
+		if !errors.Is(err, ErrCorruptIntermediate) {
+			t.Errorf("%s: got %v, want ErrCorruptIntermediate", tt.name, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q doesn't mention %q", tt.name, err, want)
+			}
+		}
+	}
+
+	// Partitions other than the damaged one are still readable
+	storeBytes(t, backend, "mr-map-7", tests[0].corrupt(bytes.Clone(original)))
+	if _, err := readTestPartition(backend, "mr-map-7", 0); err != nil {
+		t.Fatalf("undamaged partition 0: %v", err)
+	}
+}
+
+func TestIntermediateFileRejectsOtherVersions(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	writeTestIntermediate(t, backend, "mr-map-7", "map-7", testRecords())
+	data := fileBytes(t, backend, "mr-map-7")
+	data[len(intermediateMagic)] = intermediateVersion + 1
+	storeBytes(t, backend, "mr-map-7", data)
+
+	_, err := openIntermediateFile(backend, "mr-map-7")
+	if err == nil || errors.Is(err, ErrCorruptIntermediate) || !strings.Contains(err.Error(), "version 2") {
+		t.Fatalf("got %v, want an error about the format version", err)
+	}
+}
+
+func TestCopyPartition(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	records := testRecords()
+	writeTestIntermediate(t, backend, "mr-map-7", "map-7", records)
+
+	for _, partition := range []int{0, 1, 2, 5} {
+		var copied bytes.Buffer
+		if err := copyPartition(&copied, backend, "mr-map-7", partition); err != nil {
+			t.Fatalf("partition %d: %v", partition, err)
+		}
+		name := fmt.Sprintf("fetched-%d", partition)
+		storeBytes(t, backend, name, copied.Bytes())
+
+		f, err := openIntermediateFile(backend, name)
+		if err != nil {
+			t.Fatalf("copy of partition %d: %v", partition, err)
+		}
+		if f.taskID != "map-7" {
+			t.Fatalf("copy of partition %d is from task %q, want map-7", partition, f.taskID)
+		}
+		got, err := readTestPartition(backend, name, partition)
+		if err != nil {
+			t.Fatalf("copy of partition %d: %v", partition, err)
+		}
+		var want []KeyValue
+		if partition < len(records) {
+			want = records[partition]
+		}
+		if !equalRecords(got, want) {
+			t.Fatalf("copy of partition %d has %d records, want %d", partition, len(got), len(want))
+		}
+		for p := 0; p < partition; p++ {
+			if got, err := readTestPartition(backend, name, p); err != nil || len(got) != 0 {
+				t.Fatalf("copy of partition %d has %d records in partition %d, err %v", partition, len(got), p, err)
+			}
+		}
+	}
+
+	// Blocks are copied as they are, so corruption is caught by the reducer
+	data := fileBytes(t, backend, "mr-map-7")
+	f, _ := openIntermediateFile(backend, "mr-map-7")
+	data[f.sections[0].offset+blockHeaderLen] ^= 0xff
+	storeBytes(t, backend, "mr-map-7", data)
+	var copied bytes.Buffer
+	if err := copyPartition(&copied, backend, "mr-map-7", 0); err != nil {
+		t.Fatal(err)
+	}
+	storeBytes(t, backend, "fetched", copied.Bytes())
+	if _, err := readTestPartition(backend, "fetched", 0); !errors.Is(err, ErrCorruptIntermediate) {
+		t.Fatalf("got %v reading a copied corrupt block, want ErrCorruptIntermediate", err)
+	}
+}
@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"container/heap"
+
+	"go-mr/storage"
+)
+
+// sortedRun streams KeyValues from one partition of an intermediate file,
+// whose pairs are already sorted by key.
+type sortedRun struct {
+	reader  *partitionReader
+	current KeyValue
+}
+
+func (r *sortedRun) next() (bool, error) {
+	kv, ok, err := r.reader.Next()
+	if ok {
+		r.current = kv
+	}
+	return ok, err
+}
+
+// runHeap orders runs by their current key so the smallest key is on top.
+type runHeap []*sortedRun
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].current.Key < h[j].current.Key }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*sortedRun)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	n := len(old)
+	r := old[n-1]
+	*h = old[:n-1]
+	return r
+}
+
+// mergeIterator performs a k-way merge over one partition of sorted
+// intermediate files and yields one key with all of its values at a time, so
+// only the values of a single key are held in memory.
+type mergeIterator struct {
+	runs   []*sortedRun
+	heap   runHeap
+	key    string
+	values []string
+}
+
+func newMergeIterator(backend storage.Backend, paths []string, partition int) (*mergeIterator, error) {
+	it := &mergeIterator{}
+	for _, path := range paths {
+		reader, err := openPartition(backend, path, partition)
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		run := &sortedRun{reader: reader}
+		it.runs = append(it.runs, run)
+
+		ok, err := run.next()
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		if ok {
+			it.heap = append(it.heap, run)
+		}
+	}
+	heap.Init(&it.heap)
+	return it, nil
+}
+
+// Next advances to the next distinct key. It returns false once all runs
+// are exhausted.
+func (it *mergeIterator) Next() (bool, error) {
+	if it.heap.Len() == 0 {
+		return false, nil
+	}
+
+	it.key = it.heap[0].current.Key
+	it.values = it.values[:0]
+	for it.heap.Len() > 0 && it.heap[0].current.Key == it.key {
+		run := it.heap[0]
+		it.values = append(it.values, run.current.Value)
+
+		ok, err := run.next()
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			heap.Fix(&it.heap, 0)
+		} else {
+			heap.Pop(&it.heap)
+		}
+	}
+	return true, nil
+}
+
+// Key returns the current key.
+func (it *mergeIterator) Key() string { return it.key }
+
+// Values returns the values grouped under the current key. The slice is
+// reused by the next call to Next.
+func (it *mergeIterator) Values() []string { return it.values }
+
+// Close closes every underlying file.
+func (it *mergeIterator) Close() {
+	for _, run := range it.runs {
+		run.reader.Close()
+	}
+}
@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+
+	"go-mr/storage"
+)
+
+func TestMergeIteratorGroupsKeysAcrossFiles(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	files := map[string][][]KeyValue{
+		"mr-map-0": {{{Key: "x", Value: "skipped"}}, {{Key: "a", Value: "1"}, {Key: "c", Value: "1"}}},
+		"mr-map-1": {nil, {{Key: "a", Value: "2"}, {Key: "b", Value: "2"}, {Key: "b", Value: "3"}}},
+		"mr-map-2": {{{Key: "y", Value: "skipped"}}},
+	}
+	var paths []string
+	for name, records := range files {
+		writeTestIntermediate(t, backend, name, name, records)
+		paths = append(paths, name)
+	}
+
+	it, err := newMergeIterator(backend, paths, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var got []string
+	for {
+		ok, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		got = append(got, fmt.Sprintf("%s=%d", it.Key(), len(it.Values())))
+	}
+	if want := "[a=2 b=2 c=1]"; fmt.Sprint(got) != want {
+		t.Fatalf("merged %v, want %s", got, want)
+	}
+}
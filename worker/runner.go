@@ -0,0 +1,197 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-mr/masterapi"
+	"go-mr/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	minPollInterval   = 500 * time.Millisecond // Delay after the master reports no work
+	maxPollInterval   = 10 * time.Second       // Upper bound for the polling back-off
+	maxReportAttempts = 10                     // Tries to deliver a report while the master is unreachable
+)
+
+// Run registers the worker with its master and then polls for tasks,
+// executes them and reports the results until ctx is cancelled.
+func (w *WorkerNode) Run(ctx context.Context) error {
+	masterAddr := net.JoinHostPort(w.MasterNode.Address, w.MasterNode.Port)
+	conn, err := grpc.NewClient(masterAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("failed to connect to master %s: %v", masterAddr, err)
+	}
+	defer conn.Close()
+
+	client := masterapi.NewMasterApiClient(conn)
+	if err := w.register(ctx, client); err != nil {
+		return fmt.Errorf("failed to register with master %s: %v", masterAddr, err)
+	}
+	fmt.Printf("Worker %s registered with master %s\n", w.ID, masterAddr)
+
+	backoff := minPollInterval
+	for {
+		task, err := client.RequestTask(ctx, &masterapi.TaskRequest{Workerid: w.ID})
+		if status.Code(err) == codes.FailedPrecondition {
+			// The master declared this worker dead or doesn't know it,
+			// for example after missed health checks
+			log.Printf("Master refused to hand out tasks, registering again: %v", err)
+			if err = w.register(ctx, client); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("Failed to request task: %v", err)
+		} else if task.GetTasktype() != "none" {
+			backoff = minPollInterval
+			report := w.execute(task)
+			if err := reportTaskStatus(ctx, client, report); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Printf("Failed to report status of task %s: %v", task.GetTaskid(), err)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxPollInterval)
+	}
+}
+
+// register announces the worker and the address of its WorkerApi server to
+// the master.
+func (w *WorkerNode) register(ctx context.Context, client masterapi.MasterApiClient) error {
+	_, err := client.RegisterWorker(ctx, &masterapi.RegisterWorkerRequest{
+		Workerid:      w.ID,
+		Workerport:    w.Port,
+		Workeraddress: w.Address,
+	})
+	return err
+}
+
+// reportTaskStatus sends a task report to the master. While the master is
+// unreachable, for example because it is restarting, the report is retried
+// with back-off so the finished work isn't lost; a resumed master still
+// holds the lease of the attempt and accepts it.
+func reportTaskStatus(ctx context.Context, client masterapi.MasterApiClient, report *masterapi.TaskStatusReport) error {
+	backoff := minPollInterval
+	for attempt := 1; ; attempt++ {
+		_, err := client.ReportTaskStatus(ctx, report)
+		if err == nil || status.Code(err) != codes.Unavailable || attempt == maxReportAttempts {
+			return err
+		}
+		log.Printf("Master unreachable, retrying report of task %s in %s", report.GetTaskid(), backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxPollInterval)
+	}
+}
+
+// execute dispatches a task to Map or Reduce and builds the status report.
+func (w *WorkerNode) execute(task *masterapi.TaskResponse) *masterapi.TaskStatusReport {
+	report := &masterapi.TaskStatusReport{
+		Workerid: w.ID,
+		Taskid:   task.GetTaskid(),
+		Attempt:  task.GetAttempt(),
+	}
+
+	var err error
+	metadata := task.GetMetadata()
+	switch task.GetTasktype() {
+	case "map":
+		var nReduce int
+		nReduce, err = strconv.Atoi(metadata["numberOfReducers"])
+		if err != nil {
+			err = fmt.Errorf("invalid numberOfReducers %q: %v", metadata["numberOfReducers"], err)
+			break
+		}
+		var boundaries []string
+		if encoded := metadata["rangeBoundaries"]; encoded != "" {
+			if err = json.Unmarshal([]byte(encoded), &boundaries); err != nil {
+				err = fmt.Errorf("invalid rangeBoundaries: %v", err)
+				break
+			}
+		}
+		input := storage.WholeFile(task.GetInputpath())
+		if metadata["inputOffset"] != "" {
+			input.Offset, err = strconv.ParseInt(metadata["inputOffset"], 10, 64)
+			if err == nil {
+				input.Length, err = strconv.ParseInt(metadata["inputLength"], 10, 64)
+			}
+			if err != nil {
+				err = fmt.Errorf("invalid input range: %v", err)
+				break
+			}
+		}
+		var format storage.InputFormat
+		if format, err = storage.LookupInputFormat(metadata["inputFormat"]); err != nil {
+			break
+		}
+		report.Intermediatefiles, report.Combinedrecords, err = w.Map(task.GetTaskid(), input, format, task.GetOutputdir(), metadata["pluginFile"], nReduce, boundaries)
+		if err == nil {
+			w.recordMapOutput(task.GetTaskid(), report.Intermediatefiles)
+		}
+	case "reduce":
+		var reducerID int
+		reducerID, err = strconv.Atoi(metadata["reducerID"])
+		if err != nil {
+			err = fmt.Errorf("invalid reducerID %q: %v", metadata["reducerID"], err)
+			break
+		}
+		// Pull the partition from the mappers when the master lists them,
+		// otherwise read the intermediate files from shared storage
+		if sources := metadata["mapSources"]; sources != "" {
+			var mapSources []MapSource
+			mapSources, err = ParseMapSources(sources)
+			if err != nil {
+				break
+			}
+			_, err = w.ReduceFromMappers(reducerID, mapSources, task.GetOutputdir(), metadata["pluginFile"])
+			break
+		}
+		var inputFiles []string
+		if files := metadata["intermediateFiles"]; files != "" {
+			inputFiles = strings.Split(files, ",")
+		}
+		_, err = w.Reduce(reducerID, inputFiles, task.GetOutputdir(), metadata["pluginFile"])
+	default:
+		err = fmt.Errorf("unknown task type %q", task.GetTasktype())
+	}
+
+	if err != nil {
+		log.Printf("Task %s failed: %v", task.GetTaskid(), err)
+		report.Error = err.Error()
+		var fetchErr *FetchFailedError
+		if errors.As(err, &fetchErr) {
+			report.Fetchfailedtask = fetchErr.TaskID
+		}
+		return report
+	}
+	report.Success = true
+	return report
+}
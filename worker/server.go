@@ -2,11 +2,26 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+
 	"go-mr/workerapi"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type WorkerApiServer struct {
 	workerapi.UnimplementedWorkerApiServer
+	node *WorkerNode
+}
+
+func NewWorkerApiServer(node *WorkerNode) *WorkerApiServer {
+	return &WorkerApiServer{
+		node: node,
+	}
 }
 
 func (ws *WorkerApiServer) HealthCheck(ctx context.Context, req *workerapi.HealthCheckRequest) (*workerapi.HealthCheckResponse, error) {
@@ -17,3 +32,42 @@ func (ws *WorkerApiServer) HealthCheck(ctx context.Context, req *workerapi.Healt
 		Message: "Worker is healthy",
 	}, nil
 }
+
+// FetchPartition streams one partition of a map task this worker finished,
+// as an intermediate file of its own in checksummed blocks, to the reducer
+// that asked for it.
+func (ws *WorkerApiServer) FetchPartition(req *workerapi.FetchPartitionRequest, stream grpc.ServerStreamingServer[workerapi.PartitionBlock]) error {
+	path, ok := ws.node.mapOutput(req.GetTaskid(), int(req.GetPartition()))
+	if !ok {
+		return status.Errorf(codes.NotFound, "no output for partition %d of task %s", req.GetPartition(), req.GetTaskid())
+	}
+
+	file, writer := io.Pipe()
+	defer file.Close()
+	go func() {
+		writer.CloseWithError(copyPartition(writer, ws.node.Storage, path, int(req.GetPartition())))
+	}()
+
+	buf := make([]byte, shuffleBlockSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			block := &workerapi.PartitionBlock{
+				Data:     buf[:n],
+				Checksum: crc32.Checksum(buf[:n], castagnoli),
+				Offset:   offset,
+			}
+			if err := stream.Send(block); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read %s: %v", path, err)
+		}
+	}
+}
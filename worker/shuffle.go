@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-mr/storage"
+	"go-mr/workerapi"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	shuffleBlockSize     = 64 * 1024              // Bytes per streamed partition block
+	shuffleFetchAttempts = 3                      // Fetches of one partition before giving up
+	shuffleRetryDelay    = 500 * time.Millisecond // Delay before the first retry, doubled after each
+)
+
+// castagnoli is the CRC32C table used to checksum shuffled blocks.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// MapSource names a finished map task and the worker that serves its output.
+type MapSource struct {
+	TaskID  string
+	Address string // host:port of the worker's WorkerApi server
+}
+
+// FetchFailedError reports that the output of a map task couldn't be fetched
+// from the worker that ran it, usually because that worker is gone. The
+// master re-runs the map task rather than counting the failure against the
+// reduce task.
+type FetchFailedError struct {
+	TaskID string // Map task whose output couldn't be fetched
+	Err    error
+}
+
+func (e *FetchFailedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FetchFailedError) Unwrap() error {
+	return e.Err
+}
+
+// ParseMapSources parses the mapSources task metadata, a comma separated list
+// of taskID=host:port entries.
+func ParseMapSources(s string) ([]MapSource, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var sources []MapSource
+	for _, entry := range strings.Split(s, ",") {
+		taskID, addr, ok := strings.Cut(entry, "=")
+		if !ok || taskID == "" || addr == "" {
+			return nil, fmt.Errorf("invalid map source %q", entry)
+		}
+		sources = append(sources, MapSource{TaskID: taskID, Address: addr})
+	}
+	return sources, nil
+}
+
+// recordMapOutput remembers the intermediate files of a finished map task so
+// they can be served to reducers.
+func (w *WorkerNode) recordMapOutput(taskID string, files map[string]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mapOutputs[taskID] = files
+}
+
+// mapOutput returns the intermediate file of one partition of a map task.
+func (w *WorkerNode) mapOutput(taskID string, partition int) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	path, ok := w.mapOutputs[taskID][fmt.Sprint(partition)]
+	return path, ok
+}
+
+// ReduceFromMappers pulls partition reducerID of every map source from the
+// worker that ran it and reduces the fetched files. Fetched data is kept in a
+// local temporary directory that is removed afterwards.
+func (w *WorkerNode) ReduceFromMappers(reducerID int, sources []MapSource, outputDir string, pluginFile string) (string, error) {
+	dir, err := os.MkdirTemp("", fmt.Sprintf("shuffle-%d-*", reducerID))
+	if err != nil {
+		return "", fmt.Errorf("failed to create shuffle directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	local := storage.NewLocalBackend()
+	inputFiles := make([]string, 0, len(sources))
+	for _, src := range sources {
+		path := filepath.Join(dir, src.TaskID)
+		if err := fetchPartitionWithRetry(local, path, src, reducerID); err != nil {
+			return "", err
+		}
+		inputFiles = append(inputFiles, path)
+	}
+
+	return w.reduce(local, reducerID, inputFiles, outputDir, pluginFile)
+}
+
+// fetchPartitionWithRetry fetches a partition, starting over with a growing
+// delay when the transfer fails or a block is corrupted. Once every attempt
+// failed it returns a FetchFailedError.
+func fetchPartitionWithRetry(backend storage.Backend, path string, src MapSource, partition int) error {
+	delay := shuffleRetryDelay
+	var err error
+	for attempt := 1; attempt <= shuffleFetchAttempts; attempt++ {
+		if err = fetchPartition(backend, path, src, partition); err == nil {
+			return nil
+		}
+		log.Printf("Fetch %d/%d of partition %d of %s from %s failed: %v", attempt, shuffleFetchAttempts, partition, src.TaskID, src.Address, err)
+		if attempt < shuffleFetchAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return &FetchFailedError{
+		TaskID: src.TaskID,
+		Err:    fmt.Errorf("failed to fetch partition %d of %s from %s: %v", partition, src.TaskID, src.Address, err),
+	}
+}
+
+// fetchPartition streams one partition into path, checking that every block
+// arrives in order and matches its checksum.
+func fetchPartition(backend storage.Backend, path string, src MapSource, partition int) error {
+	conn, err := grpc.NewClient(src.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := workerapi.NewWorkerApiClient(conn).FetchPartition(ctx, &workerapi.FetchPartitionRequest{
+		Taskid:    src.TaskID,
+		Partition: int32(partition),
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := backend.Create(path)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for {
+		block, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			file.Abort()
+			return err
+		}
+
+		if block.GetOffset() != offset {
+			file.Abort()
+			return fmt.Errorf("block at offset %d arrived, expected offset %d", block.GetOffset(), offset)
+		}
+		if sum := crc32.Checksum(block.GetData(), castagnoli); sum != block.GetChecksum() {
+			file.Abort()
+			return fmt.Errorf("checksum mismatch in block at offset %d: got %08x, want %08x", offset, sum, block.GetChecksum())
+		}
+		if _, err := file.Write(block.GetData()); err != nil {
+			file.Abort()
+			return err
+		}
+		offset += int64(len(block.GetData()))
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMapSources(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []MapSource
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "job-1-map-0=10.0.0.1:7000", want: []MapSource{{TaskID: "job-1-map-0", Address: "10.0.0.1:7000"}}},
+		{
+			in: "job-1-map-0=host-a:7000,job-1-map-1=[::1]:7001",
+			want: []MapSource{
+				{TaskID: "job-1-map-0", Address: "host-a:7000"},
+				{TaskID: "job-1-map-1", Address: "[::1]:7001"},
+			},
+		},
+		{in: "job-1-map-0", wantErr: true},
+		{in: "=host-a:7000", wantErr: true},
+		{in: "job-1-map-0=", wantErr: true},
+		{in: "job-1-map-0=host-a:7000,", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMapSources(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMapSources(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMapSources(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
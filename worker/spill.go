@@ -0,0 +1,398 @@
+package worker
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"go-mr/storage"
+)
+
+// DefaultMapBufferBytes is how much map output a worker buffers in memory
+// before it spills a sorted run to disk.
+const DefaultMapBufferBytes = 64 << 20
+
+// kvOverhead approximates the memory a buffered pair uses on top of its key
+// and value bytes.
+const kvOverhead = 48
+
+// partitionedKV is a map output pair tagged with the reducer it belongs to.
+type partitionedKV struct {
+	Partition int
+	Key       string
+	Value     string
+}
+
+// comparePartitioned orders pairs by partition and then by key.
+func comparePartitioned(a, b partitionedKV) int {
+	if c := cmp.Compare(a.Partition, b.Partition); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Key, b.Key)
+}
+
+// mapOutputBuffer collects the pairs emitted by a map task in memory. Once
+// the buffered pairs exceed the limit they are sorted by partition and key
+// and spilled to a run file, and the runs are merged into the final
+// per-reducer files at the end of the task. With a combiner, the values of
+// each key are combined whenever a sorted run is written.
+type mapOutputBuffer struct {
+	nReduce  int
+	limit    int64
+	size     int64
+	records  []partitionedKV
+	spillDir string
+	spills   []string
+	combiner Combiner
+	combined int64 // Records removed by the combiner
+}
+
+func newMapOutputBuffer(nReduce int, limit int64, combiner Combiner) *mapOutputBuffer {
+	if limit <= 0 {
+		limit = DefaultMapBufferBytes
+	}
+	return &mapOutputBuffer{nReduce: nReduce, limit: limit, combiner: combiner}
+}
+
+// add buffers one pair and spills the buffer if it is full.
+func (b *mapOutputBuffer) add(partition int, kv KeyValue) error {
+	b.records = append(b.records, partitionedKV{Partition: partition, Key: kv.Key, Value: kv.Value})
+	b.size += int64(len(kv.Key) + len(kv.Value) + kvOverhead)
+	if b.size >= b.limit {
+		return b.spill()
+	}
+	return nil
+}
+
+// sortRecords sorts the buffer by partition and key. The sort is stable so
+// values of one key keep the order the mapper emitted them in.
+func (b *mapOutputBuffer) sortRecords() {
+	slices.SortStableFunc(b.records, comparePartitioned)
+}
+
+// spill writes the buffer as a sorted run to the spill directory, in the
+// intermediate file format.
+func (b *mapOutputBuffer) spill() error {
+	if b.spillDir == "" {
+		dir, err := os.MkdirTemp("", "map-spill-*")
+		if err != nil {
+			return fmt.Errorf("failed to create spill directory: %v", err)
+		}
+		b.spillDir = dir
+	}
+
+	b.sortRecords()
+	b.combineRecords()
+	path := filepath.Join(b.spillDir, fmt.Sprintf("spill-%04d", len(b.spills)))
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %v", err)
+	}
+
+	writer, err := newIntermediateWriter(file, filepath.Base(path), b.nReduce)
+	if err == nil {
+		for _, kv := range b.records {
+			if err = writer.Write(kv.Partition, kv.Key, kv.Value); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close spill file: %v", err)
+	}
+
+	b.spills = append(b.spills, path)
+	b.records = nil
+	b.size = 0
+	return nil
+}
+
+// writePartitions writes the intermediate file of the task, every partition
+// sorted by key, and returns the reducerID -> file path map. Output that
+// never spilled is written straight from memory; otherwise every run is
+// merged.
+func (b *mapOutputBuffer) writePartitions(backend storage.Backend, outputDir string, taskID string) (map[string]string, error) {
+	if len(b.spills) == 0 {
+		b.sortRecords()
+		b.combineRecords()
+		return writeIntermediateFile(backend, outputDir, taskID, b.nReduce, sliceIterator(b.records))
+	}
+
+	if len(b.records) > 0 {
+		if err := b.spill(); err != nil {
+			return nil, err
+		}
+	}
+
+	merger, err := newSpillMerger(storage.NewLocalBackend(), b.spills)
+	if err != nil {
+		return nil, err
+	}
+	defer merger.close()
+
+	// Runs were combined on their own, so keys spread over several runs
+	// are combined once more while merging.
+	next := merger.next
+	if b.combiner != nil {
+		next = b.combining(next)
+	}
+	return writeIntermediateFile(backend, outputDir, taskID, b.nReduce, next)
+}
+
+// combineRecords replaces every group of equal keys in the sorted buffer
+// with the single record produced by the combiner.
+func (b *mapOutputBuffer) combineRecords() {
+	if b.combiner == nil {
+		return
+	}
+
+	// The combined records are written behind the read position, so the
+	// buffer can be reused in place.
+	next := b.combining(sliceIterator(b.records))
+	combined := b.records[:0]
+	for {
+		kv, ok, _ := next()
+		if !ok {
+			break
+		}
+		combined = append(combined, kv)
+	}
+	b.records = combined
+}
+
+// combining wraps an iterator over pairs sorted by partition and key and
+// yields one combined pair per key, counting the records it removed.
+func (b *mapOutputBuffer) combining(next func() (partitionedKV, bool, error)) func() (partitionedKV, bool, error) {
+	var (
+		pending partitionedKV
+		hasNext bool
+		values  []string
+	)
+	kv, ok, err := next()
+	pending, hasNext = kv, ok
+
+	return func() (partitionedKV, bool, error) {
+		if err != nil || !hasNext {
+			return partitionedKV{}, false, err
+		}
+
+		group := pending
+		values = append(values[:0], group.Value)
+		for {
+			kv, ok, err = next()
+			if err != nil {
+				return partitionedKV{}, false, err
+			}
+			if !ok || kv.Partition != group.Partition || kv.Key != group.Key {
+				pending, hasNext = kv, ok
+				break
+			}
+			values = append(values, kv.Value)
+		}
+
+		if len(values) > 1 {
+			group.Value = b.combiner(group.Key, values)
+			b.combined += int64(len(values) - 1)
+		}
+		return group, true, nil
+	}
+}
+
+// sliceIterator yields the pairs of records in order.
+func sliceIterator(records []partitionedKV) func() (partitionedKV, bool, error) {
+	i := 0
+	return func() (partitionedKV, bool, error) {
+		if i == len(records) {
+			return partitionedKV{}, false, nil
+		}
+		i++
+		return records[i-1], true, nil
+	}
+}
+
+// close removes the spill files.
+func (b *mapOutputBuffer) close() {
+	if b.spillDir != "" {
+		os.RemoveAll(b.spillDir)
+	}
+}
+
+// writeIntermediateFile consumes pairs ordered by partition and key and
+// writes them to the intermediate file of the task, which holds every
+// partition and is listed under each reducerID. The backend only publishes a
+// file once it is complete, so a crashed attempt never leaves a partial file
+// behind at the final path.
+func writeIntermediateFile(backend storage.Backend, outputDir string, taskID string, nReduce int, next func() (partitionedKV, bool, error)) (map[string]string, error) {
+	path := filepath.Join(outputDir, fmt.Sprintf("mr-%s", taskID))
+	file, err := backend.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create intermediate file: %v", err)
+	}
+
+	writer, err := newIntermediateWriter(file, taskID, nReduce)
+	if err != nil {
+		file.Abort()
+		return nil, fmt.Errorf("failed to write intermediate file: %v", err)
+	}
+	for {
+		kv, ok, err := next()
+		if err != nil {
+			file.Abort()
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if kv.Partition < 0 || kv.Partition >= nReduce {
+			file.Abort()
+			return nil, fmt.Errorf("pair for partition %d is out of range", kv.Partition)
+		}
+		if err := writer.Write(kv.Partition, kv.Key, kv.Value); err != nil {
+			file.Abort()
+			return nil, fmt.Errorf("failed to write intermediate file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		file.Abort()
+		return nil, fmt.Errorf("failed to write intermediate file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close intermediate file: %v", err)
+	}
+
+	intermediateFiles := make(map[string]string, nReduce)
+	for r := 0; r < nReduce; r++ {
+		intermediateFiles[strconv.Itoa(r)] = path
+	}
+	return intermediateFiles, nil
+}
+
+// spillRun streams the pairs of one spill file, partition by partition.
+type spillRun struct {
+	backend   storage.Backend
+	file      *intermediateFile
+	partition int
+	reader    *partitionReader // Reader of partition, nil before it is opened
+	index     int              // Position of the run, used to keep the merge stable
+	current   partitionedKV
+}
+
+func (r *spillRun) next() (bool, error) {
+	for {
+		if r.reader == nil {
+			if r.partition >= len(r.file.sections) {
+				return false, nil
+			}
+			reader, err := r.file.openPartition(r.backend, r.partition)
+			if err != nil {
+				return false, err
+			}
+			r.reader = reader
+		}
+
+		kv, ok, err := r.reader.Next()
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			r.current = partitionedKV{Partition: r.partition, Key: kv.Key, Value: kv.Value}
+			return true, nil
+		}
+		r.reader.Close()
+		r.reader = nil
+		r.partition++
+	}
+}
+
+func (r *spillRun) close() {
+	if r.reader != nil {
+		r.reader.Close()
+	}
+}
+
+// spillHeap orders runs by their current pair, earlier runs first on ties.
+type spillHeap []*spillRun
+
+func (h spillHeap) Len() int { return len(h) }
+func (h spillHeap) Less(i, j int) bool {
+	if c := comparePartitioned(h[i].current, h[j].current); c != 0 {
+		return c < 0
+	}
+	return h[i].index < h[j].index
+}
+func (h spillHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *spillHeap) Push(x any)   { *h = append(*h, x.(*spillRun)) }
+func (h *spillHeap) Pop() any {
+	old := *h
+	n := len(old)
+	r := old[n-1]
+	*h = old[:n-1]
+	return r
+}
+
+// spillMerger performs a k-way merge over the spill runs of a map task.
+type spillMerger struct {
+	runs []*spillRun
+	heap spillHeap
+}
+
+func newSpillMerger(backend storage.Backend, paths []string) (*spillMerger, error) {
+	m := &spillMerger{}
+	for i, path := range paths {
+		file, err := openIntermediateFile(backend, path)
+		if err != nil {
+			m.close()
+			return nil, fmt.Errorf("failed to open spill file: %v", err)
+		}
+		run := &spillRun{backend: backend, file: file, index: i}
+		m.runs = append(m.runs, run)
+
+		ok, err := run.next()
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+		if ok {
+			m.heap = append(m.heap, run)
+		}
+	}
+	heap.Init(&m.heap)
+	return m, nil
+}
+
+// next returns the smallest pair left in any run.
+func (m *spillMerger) next() (partitionedKV, bool, error) {
+	if m.heap.Len() == 0 {
+		return partitionedKV{}, false, nil
+	}
+
+	run := m.heap[0]
+	kv := run.current
+	ok, err := run.next()
+	if err != nil {
+		return partitionedKV{}, false, err
+	}
+	if ok {
+		heap.Fix(&m.heap, 0)
+	} else {
+		heap.Pop(&m.heap)
+	}
+	return kv, true, nil
+}
+
+func (m *spillMerger) close() {
+	for _, run := range m.runs {
+		run.close()
+	}
+}
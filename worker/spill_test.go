@@ -0,0 +1,187 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"go-mr/storage"
+)
+
+// mapTestOutput feeds pairs through a map output buffer that holds at most
+// limit bytes and returns the records of every partition of the resulting
+// intermediate file, with the number of spills and records combined.
+func mapTestOutput(t *testing.T, pairs []partitionedKV, nReduce int, limit int64, combiner Combiner) ([][]KeyValue, int, int64) {
+	t.Helper()
+	buffer := newMapOutputBuffer(nReduce, limit, combiner)
+	defer buffer.close()
+	for _, kv := range pairs {
+		if err := buffer.add(kv.Partition, KeyValue{Key: kv.Key, Value: kv.Value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backend := storage.NewMemoryBackend()
+	files, err := buffer.writePartitions(backend, "out", "map-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != nReduce {
+		t.Fatalf("got files for %d reducers, want %d", len(files), nReduce)
+	}
+
+	partitions := make([][]KeyValue, nReduce)
+	for p := range partitions {
+		if partitions[p], err = readTestPartition(backend, files[fmt.Sprint(p)], p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return partitions, len(buffer.spills), buffer.combined
+}
+
+// testPairs emits every key of three partitions several times, out of
+// order, numbering the values in the order they were emitted.
+func testPairs() []partitionedKV {
+	var pairs []partitionedKV
+	for round := 0; round < 4; round++ {
+		for i := 9; i >= 0; i-- {
+			pairs = append(pairs, partitionedKV{
+				Partition: i % 3,
+				Key:       fmt.Sprintf("key-%d", i),
+				Value:     fmt.Sprint(round),
+			})
+		}
+	}
+	return pairs
+}
+
+func TestMapOutputBufferSpillsAndMerges(t *testing.T) {
+	inMemory, spills, _ := mapTestOutput(t, testPairs(), 3, 0, nil)
+	if spills != 0 {
+		t.Fatalf("default buffer spilled %d times", spills)
+	}
+	for p, records := range inMemory {
+		for i, kv := range records {
+			if kv.Key != fmt.Sprintf("key-%d", p+3*(i/4)) || kv.Value != fmt.Sprint(i%4) {
+				t.Fatalf("record %d of partition %d is %v, want keys sorted with values in emission order", i, p, kv)
+			}
+		}
+	}
+
+	// A buffer holding a few pairs spills many sorted runs, and merging
+	// them must give the same result
+	spilled, spills, _ := mapTestOutput(t, testPairs(), 3, 4*(kvOverhead+6), nil)
+	if spills < 5 {
+		t.Fatalf("buffer spilled %d times, want several runs to merge", spills)
+	}
+	for p := range inMemory {
+		if !equalRecords(spilled[p], inMemory[p]) {
+			t.Fatalf("partition %d after spilling is %v, want %v", p, spilled[p], inMemory[p])
+		}
+	}
+}
+
+func TestMapOutputBufferRemovesSpills(t *testing.T) {
+	buffer := newMapOutputBuffer(1, 1, nil)
+	if err := buffer.add(0, KeyValue{Key: "a", Value: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(buffer.spills) != 1 {
+		t.Fatalf("buffer spilled %d times, want 1", len(buffer.spills))
+	}
+	buffer.close()
+	if _, err := os.Stat(buffer.spillDir); !os.IsNotExist(err) {
+		t.Fatalf("spill directory %s still exists after close: %v", buffer.spillDir, err)
+	}
+}
+
+func TestSpillMergerKeepsRunOrderOnTies(t *testing.T) {
+	backend := storage.NewMemoryBackend()
+	runs := [][][]KeyValue{
+		{{{Key: "a", Value: "run0"}, {Key: "c", Value: "run0"}}, {{Key: "b", Value: "run0"}}},
+		{{{Key: "a", Value: "run1"}}, {{Key: "a", Value: "run1"}, {Key: "b", Value: "run1"}}},
+		{nil, nil},
+	}
+	var paths []string
+	for i, records := range runs {
+		path := fmt.Sprintf("spill-%d", i)
+		writeTestIntermediate(t, backend, path, path, records)
+		paths = append(paths, path)
+	}
+
+	merger, err := newSpillMerger(backend, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer merger.close()
+
+	var got []string
+	for {
+		kv, ok, err := merger.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		got = append(got, fmt.Sprintf("%d:%s:%s", kv.Partition, kv.Key, kv.Value))
+	}
+	want := []string{"0:a:run0", "0:a:run1", "0:c:run0", "1:a:run1", "1:b:run0", "1:b:run1"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("merged %v, want %v", got, want)
+	}
+}
+
+// sumValues is a combiner adding up numeric values.
+func sumValues(key string, values []string) string {
+	total := 0
+	for _, v := range values {
+		var n int
+		fmt.Sscan(v, &n)
+		total += n
+	}
+	return fmt.Sprint(total)
+}
+
+func TestMapOutputBufferCombines(t *testing.T) {
+	for _, limit := range []int64{0, 4 * (kvOverhead + 6)} {
+		partitions, spills, combined := mapTestOutput(t, testPairs(), 3, limit, sumValues)
+		for p, records := range partitions {
+			for i, kv := range records {
+				if kv.Key != fmt.Sprintf("key-%d", p+3*i) || kv.Value != "6" {
+					t.Fatalf("limit %d, %d spills: record %d of partition %d is %v, want one record per key with the sum 6", limit, spills, i, p, kv)
+				}
+			}
+		}
+		// Ten keys emitted four times each, however the runs were cut
+		if combined != 30 {
+			t.Fatalf("limit %d, %d spills: combiner removed %d records, want 30", limit, spills, combined)
+		}
+	}
+}
+
+func TestCombiningKeepsPartitionsApart(t *testing.T) {
+	buffer := newMapOutputBuffer(2, 0, sumValues)
+	next := buffer.combining(sliceIterator([]partitionedKV{
+		{Partition: 0, Key: "a", Value: "1"},
+		{Partition: 0, Key: "a", Value: "2"},
+		{Partition: 0, Key: "b", Value: "3"},
+		{Partition: 1, Key: "b", Value: "4"},
+		{Partition: 1, Key: "b", Value: "5"},
+	}))
+
+	var got []string
+	for {
+		kv, ok, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		got = append(got, fmt.Sprintf("%d:%s=%s", kv.Partition, kv.Key, kv.Value))
+	}
+	if want := "[0:a=3 0:b=3 1:b=9]"; fmt.Sprint(got) != want || buffer.combined != 2 {
+		t.Fatalf("combined into %v removing %d records, want %s removing 2", got, buffer.combined, want)
+	}
+}
@@ -1,30 +1,49 @@
 package worker
 
-import "errors"
+import (
+	"sync"
 
-type KeyValue struct {
-	Key   string
-	Value string
-}
+	"go-mr/storage"
+	"go-mr/types"
+)
+
+// KeyValue, Mapper and Reducer are shared with the plugins through the types
+// package so that symbols looked up from a plugin match the worker's types.
+type KeyValue = types.KeyValue
+
+// Mapper is the function signature exported by plugins as Map.
+type Mapper = types.Mapper
+
+// Reducer is the function signature exported by plugins as Reduce.
+type Reducer = types.Reducer
 
-// NewKeyValue creates a new KeyValue instance.
-type Mapper func(record string) []KeyValue
+// Combiner is the optional function signature exported by plugins as Combine.
+type Combiner = types.Combiner
 
-// Reducer is a function type that takes a key and a slice of values,
-type Reducer func(key string, values []string) string
+// Partitioner is the optional function signature exported by plugins as
+// Partition.
+type Partitioner = types.Partitioner
 
-var ErrInvalidMapper = errors.New("invalid mapper: plugin symbol Map does not implement Mapper interface")
+var ErrInvalidMapper = types.ErrInvalidMapper
 
-var ErrInvalidReducer = errors.New("invalid reducer: plugin symbol Reduce does not implement Reducer interface")
+var ErrInvalidReducer = types.ErrInvalidReducer
 
 type WorkerNode struct {
-	ID         string      // Unique identifier for the worker
-	Address    string      // Address of the worker node
-	Port       string      // Port number for the worker node
-	Active     bool        // Indicates if the worker is currently active
-	MasterNode *MasterNode // Reference to the master node this worker is connected to
-	Mapper     Mapper      // Function to perform map tasks
-	Reducer    Reducer     // Function to perform reduce tasks
+	ID          string          // Unique identifier for the worker
+	Address     string          // Address of the worker node
+	Port        string          // Port number for the worker node
+	Active      bool            // Indicates if the worker is currently active
+	MasterNode  *MasterNode     // Reference to the master node this worker is connected to
+	Mapper      Mapper          // Function to perform map tasks
+	Reducer     Reducer         // Function to perform reduce tasks
+	Combiner    Combiner        // Optional map-side combiner, nil if the plugin has none
+	Partitioner Partitioner     // Optional key partitioner, nil to hash partition keys
+	Storage     storage.Backend // Backend holding splits, intermediate files and outputs
+
+	MapBufferBytes int64 // Map output buffered in memory before spilling, DefaultMapBufferBytes if zero
+
+	mu         sync.Mutex                   // Guards mapOutputs
+	mapOutputs map[string]map[string]string // map taskID -> reducerID -> file path served to reducers
 }
 
 type MasterNode struct {
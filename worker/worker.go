@@ -1,9 +1,16 @@
 package worker
 
 import (
+	"bufio"
 	"crypto/rand"
 	"fmt"
-	"plugin"
+	"hash/fnv"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"go-mr/storage"
+	"go-mr/types"
 )
 
 func NewWorkerNode(address, port, masterAddress, masterPort string) (*WorkerNode, error) {
@@ -23,25 +30,165 @@ func NewWorkerNode(address, port, masterAddress, masterPort string) (*WorkerNode
 		},
 		Mapper:  nil, // Mapper function will be set later
 		Reducer: nil, // Reducer function will be set later
+		Storage: storage.NewLocalBackend(),
+
+		mapOutputs: make(map[string]map[string]string),
 	}, nil
 }
 
-func (w *WorkerNode) Map(inputFile string, outputFile string, pluginFile string) error {
-
-	w.LoadMapper(pluginFile)
+// Map runs the plugin mapper over every record of the input range, read as
+// format, and hash partitions the emitted pairs into nReduce intermediate
+// files written to outputDir, or uses the plugin's own partitioner if it
+// exports one. For total order jobs boundaries holds the nReduce-1 sorted
+// keys that split the key space into ranges, and takes precedence over both.
+// If the plugin exports a combiner it is run over every sorted run before it
+// is written. It returns the reducerID -> file path map reported to the
+// master and the number of records the combiner removed.
+func (w *WorkerNode) Map(taskID string, input storage.InputRange, format storage.InputFormat, outputDir string, pluginFile string, nReduce int, boundaries []string) (intermediateFiles map[string]string, combined int64, err error) {
+	if nReduce <= 0 {
+		return nil, 0, fmt.Errorf("invalid number of reducers: %d", nReduce)
+	}
+	if err := w.LoadMapper(pluginFile); err != nil {
+		return nil, 0, fmt.Errorf("failed to load mapper: %v", err)
+	}
 	if w.Mapper == nil {
-		return fmt.Errorf("no mapper function loaded")
+		return nil, 0, fmt.Errorf("no mapper function loaded")
+	}
+	if err := w.LoadCombiner(pluginFile); err != nil {
+		return nil, 0, fmt.Errorf("failed to load combiner: %v", err)
+	}
+	if err := w.LoadPartitioner(pluginFile); err != nil {
+		return nil, 0, fmt.Errorf("failed to load partitioner: %v", err)
 	}
+	partitionKey, err := w.partitioner(nReduce, boundaries)
+	if err != nil {
+		return nil, 0, err
+	}
+	return w.mapInput(taskID, input, format, outputDir, nReduce, partitionKey)
+}
 
-	fmt.Printf("Worker %s is processing map task on file %s\n", w.ID, inputFile)
+// partitioner returns the function sending keys to one of nReduce reducers:
+// a range partitioner over boundaries if there are any, else the plugin's
+// partitioner if it has one, else the FNV-1a hash.
+func (w *WorkerNode) partitioner(nReduce int, boundaries []string) (Partitioner, error) {
+	if len(boundaries) > 0 {
+		if len(boundaries) != nReduce-1 {
+			return nil, fmt.Errorf("got %d range boundaries for %d reducers", len(boundaries), nReduce)
+		}
+		return rangePartitioner(boundaries), nil
+	}
+	if w.Partitioner != nil {
+		return w.Partitioner, nil
+	}
+	return partition, nil
+}
 
-	return nil
+// mapInput runs the loaded mapper over the records of input and writes the
+// emitted pairs, partitioned by partitionKey, to the intermediate file of the
+// task. A partitioner returning a reducer outside 0 to nReduce-1 fails the
+// task.
+func (w *WorkerNode) mapInput(taskID string, input storage.InputRange, format storage.InputFormat, outputDir string, nReduce int, partitionKey Partitioner) (intermediateFiles map[string]string, combined int64, err error) {
+	if input.Length < 0 {
+		fmt.Printf("Worker %s is processing map task %s on file %s\n", w.ID, taskID, input.File)
+	} else {
+		fmt.Printf("Worker %s is processing map task %s on %s bytes %d-%d\n", w.ID, taskID, input.File, input.Offset, input.Offset+input.Length)
+	}
+
+	records, file, err := storage.OpenRecords(w.Storage, input, format)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open input file: %v", err)
+	}
+	defer file.Close()
+
+	buffer := newMapOutputBuffer(nReduce, w.MapBufferBytes, w.Combiner)
+	defer buffer.close()
+
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read input file: %v", err)
+		}
+		for _, kv := range w.Mapper(string(record)) {
+			r := partitionKey(kv.Key, nReduce)
+			if r < 0 || r >= nReduce {
+				return nil, 0, fmt.Errorf("partitioner returned %d for key %q, want 0 to %d", r, kv.Key, nReduce-1)
+			}
+			if err := buffer.add(r, kv); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	// Reducers merge the intermediate files of a partition, so every file
+	// must be sorted by key.
+	intermediateFiles, err = buffer.writePartitions(w.Storage, outputDir, taskID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return intermediateFiles, buffer.combined, nil
 }
-func (w *WorkerNode) Reduce(inputFiles []string, outputFile string, pluginFile string) error {
 
-	fmt.Printf("Worker %s is processing reduce task on files %v\n", w.ID, inputFiles)
+// partition maps a key to one of nReduce buckets using an FNV-1a hash.
+func partition(key string, nReduce int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()&0x7fffffff) % nReduce
+}
 
-	return nil
+// Reduce merges the sorted intermediate files of one partition, calls the
+// plugin reducer once per key in sorted order and writes the results to a
+// part-NNNNN file in outputDir. It returns the path of the output file.
+func (w *WorkerNode) Reduce(reducerID int, inputFiles []string, outputDir string, pluginFile string) (string, error) {
+	return w.reduce(w.Storage, reducerID, inputFiles, outputDir, pluginFile)
+}
+
+// reduce is Reduce with the input files read from inputs, which differs from
+// the worker's storage when the files were fetched from other workers.
+func (w *WorkerNode) reduce(inputs storage.Backend, reducerID int, inputFiles []string, outputDir string, pluginFile string) (string, error) {
+	if err := w.LoadReducer(pluginFile); err != nil {
+		return "", fmt.Errorf("failed to load reducer: %v", err)
+	}
+	if w.Reducer == nil {
+		return "", fmt.Errorf("no reducer function loaded")
+	}
+
+	fmt.Printf("Worker %s is processing reduce task %d on files %v\n", w.ID, reducerID, inputFiles)
+
+	it, err := newMergeIterator(inputs, inputFiles, reducerID)
+	if err != nil {
+		return "", err
+	}
+	defer it.Close()
+
+	outputFile := filepath.Join(outputDir, fmt.Sprintf("part-%05d", reducerID))
+	file, err := w.Storage.Create(outputFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output file: %v", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for {
+		ok, err := it.Next()
+		if err != nil {
+			file.Abort()
+			return "", err
+		}
+		if !ok {
+			break
+		}
+		fmt.Fprintf(writer, "%s %s\n", it.Key(), w.Reducer(it.Key(), it.Values()))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Abort()
+		return "", fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close output file: %v", err)
+	}
+	return outputFile, nil
 }
 
 func GenerateUniqueID() (string, error) {
@@ -61,37 +208,51 @@ func GenerateUniqueID() (string, error) {
 	return string(b), nil
 }
 
+// rangePartitioner sends a key to the range it falls in: keys below
+// boundaries[0] go to reducer 0 and keys from boundaries[i-1] up to
+// boundaries[i] go to reducer i.
+func rangePartitioner(boundaries []string) Partitioner {
+	return func(key string, n int) int {
+		return sort.Search(len(boundaries), func(i int) bool { return key < boundaries[i] })
+	}
+}
+
 func (w *WorkerNode) LoadMapper(path string) error {
-	p, err := plugin.Open(path)
+	mapper, err := types.LoadMapper(path)
 	if err != nil {
 		return err
 	}
-	sym, err := p.Lookup("Map")
+	w.Mapper = mapper
+	return nil
+}
+
+func (w *WorkerNode) LoadReducer(path string) error {
+	reducer, err := types.LoadReducer(path)
 	if err != nil {
 		return err
 	}
-
-	rawFunc, ok := sym.(func(string) []KeyValue)
-	if !ok {
-		return fmt.Errorf("invalid mapper signature: %T", sym)
-	}
-	w.Mapper = Mapper(rawFunc)
+	w.Reducer = reducer
 	return nil
 }
 
-func (w *WorkerNode) LoadReducer(path string) error {
-	p, err := plugin.Open(path)
+// LoadCombiner looks up the optional Combine symbol. A plugin without one
+// leaves Combiner nil.
+func (w *WorkerNode) LoadCombiner(path string) error {
+	combiner, err := types.LoadCombiner(path)
 	if err != nil {
 		return err
 	}
-	sym, err := p.Lookup("Reduce")
+	w.Combiner = combiner
+	return nil
+}
+
+// LoadPartitioner looks up the optional Partition symbol. A plugin without
+// one leaves Partitioner nil and keys are hash partitioned.
+func (w *WorkerNode) LoadPartitioner(path string) error {
+	partitioner, err := types.LoadPartitioner(path)
 	if err != nil {
 		return err
 	}
-	rawFunc, ok := sym.(func(string, []string) string)
-	if !ok {
-		return fmt.Errorf("invalid reducer signature: %T", sym)
-	}
-	w.Reducer = Reducer(rawFunc)
+	w.Partitioner = partitioner
 	return nil
 }
@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go-mr/storage"
+)
+
+// newTestWorker returns a worker with an in-memory backend holding input
+// and a mapper emitting every word of a line.
+func newTestWorker(t *testing.T, input string) *WorkerNode {
+	t.Helper()
+	backend := storage.NewMemoryBackend()
+	storeBytes(t, backend, "input.txt", []byte(input))
+	return &WorkerNode{
+		ID:      "w1",
+		Storage: backend,
+		Mapper: func(line string) []KeyValue {
+			var kvs []KeyValue
+			for _, word := range strings.Fields(line) {
+				kvs = append(kvs, KeyValue{Key: word, Value: "1"})
+			}
+			return kvs
+		},
+		mapOutputs: make(map[string]map[string]string),
+	}
+}
+
+// byFirstLetter is a plugin partitioner sending words starting with a-m to
+// reducer 0 and the others to reducer 1.
+func byFirstLetter(key string, nReduce int) int {
+	if key < "n" {
+		return 0
+	}
+	return nReduce - 1
+}
+
+func TestMapUsesPluginPartitioner(t *testing.T) {
+	w := newTestWorker(t, "apple zebra\nmango nut\n")
+	w.Partitioner = byFirstLetter
+	partitionKey, err := w.partitioner(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _, err := w.mapInput("map-0", storage.WholeFile("input.txt"), storage.LineFormat{}, "out", 2, partitionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range []string{"[apple mango]", "[nut zebra]"} {
+		records, err := readTestPartition(w.Storage, files[fmt.Sprint(p)], p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, kv := range records {
+			keys = append(keys, kv.Key)
+		}
+		if fmt.Sprint(keys) != want {
+			t.Fatalf("partition %d holds %v, want %s", p, keys, want)
+		}
+	}
+}
+
+func TestMapRejectsPartitionOutOfRange(t *testing.T) {
+	for _, bad := range []int{-1, 3} {
+		w := newTestWorker(t, "apple zebra\n")
+		partitionKey := func(key string, nReduce int) int {
+			if key == "zebra" {
+				return bad
+			}
+			return 0
+		}
+
+		_, _, err := w.mapInput("map-0", storage.WholeFile("input.txt"), storage.LineFormat{}, "out", 3, partitionKey)
+		want := fmt.Sprintf(`partitioner returned %d for key "zebra", want 0 to 2`, bad)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("got %v, want an error containing %q", err, want)
+		}
+		if names, _ := w.Storage.List("out"); len(names) != 0 {
+			t.Fatalf("failed map task left %v behind", names)
+		}
+	}
+}
+
+func TestRangePartitioner(t *testing.T) {
+	partitionKey := rangePartitioner([]string{"g", "p"})
+	tests := map[string]int{
+		"":      0,
+		"apple": 0,
+		"f~":    0,
+		"g":     1, // A boundary starts the range above it
+		"grape": 1,
+		"p":     2,
+		"zebra": 2,
+	}
+	for key, want := range tests {
+		if got := partitionKey(key, 3); got != want {
+			t.Errorf("key %q went to reducer %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestRangeBoundariesTakePrecedence(t *testing.T) {
+	w := newTestWorker(t, "")
+	w.Partitioner = func(key string, nReduce int) int { return 0 }
+
+	partitionKey, err := w.partitioner(3, []string{"g", "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := partitionKey("zebra", 3); got != 2 {
+		t.Fatalf("zebra went to reducer %d, want 2 from the range boundaries", got)
+	}
+
+	if _, err := w.partitioner(3, []string{"g"}); err == nil || !strings.Contains(err.Error(), "got 1 range boundaries for 3 reducers") {
+		t.Fatalf("got %v, want an error about the boundary count", err)
+	}
+
+	w.Partitioner = nil
+	if partitionKey, err = w.partitioner(3, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := partitionKey("zebra", 3), partition("zebra", 3); got != want {
+		t.Fatalf("zebra went to reducer %d without boundaries or plugin partitioner, want hash partition %d", got, want)
+	}
+}
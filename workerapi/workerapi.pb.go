@@ -109,6 +109,118 @@ func (x *HealthCheckResponse) GetMessage() string {
 	return ""
 }
 
+type FetchPartitionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Taskid        string                 `protobuf:"bytes,1,opt,name=taskid,proto3" json:"taskid,omitempty"`
+	Partition     int32                  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchPartitionRequest) Reset() {
+	*x = FetchPartitionRequest{}
+	mi := &file_workerapi_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchPartitionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchPartitionRequest) ProtoMessage() {}
+
+func (x *FetchPartitionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_workerapi_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchPartitionRequest.ProtoReflect.Descriptor instead.
+func (*FetchPartitionRequest) Descriptor() ([]byte, []int) {
+	return file_workerapi_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FetchPartitionRequest) GetTaskid() string {
+	if x != nil {
+		return x.Taskid
+	}
+	return ""
+}
+
+func (x *FetchPartitionRequest) GetPartition() int32 {
+	if x != nil {
+		return x.Partition
+	}
+	return 0
+}
+
+type PartitionBlock struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Checksum      uint32                 `protobuf:"varint,2,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	Offset        int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PartitionBlock) Reset() {
+	*x = PartitionBlock{}
+	mi := &file_workerapi_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PartitionBlock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PartitionBlock) ProtoMessage() {}
+
+func (x *PartitionBlock) ProtoReflect() protoreflect.Message {
+	mi := &file_workerapi_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PartitionBlock.ProtoReflect.Descriptor instead.
+func (*PartitionBlock) Descriptor() ([]byte, []int) {
+	return file_workerapi_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PartitionBlock) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *PartitionBlock) GetChecksum() uint32 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+func (x *PartitionBlock) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
 var File_workerapi_proto protoreflect.FileDescriptor
 
 const file_workerapi_proto_rawDesc = "" +
@@ -117,9 +229,17 @@ const file_workerapi_proto_rawDesc = "" +
 	"\x12HealthCheckRequest\"I\n" +
 	"\x13HealthCheckResponse\x12\x18\n" +
 	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2E\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"M\n" +
+	"\x15FetchPartitionRequest\x12\x16\n" +
+	"\x06taskid\x18\x01 \x01(\tR\x06taskid\x12\x1c\n" +
+	"\tpartition\x18\x02 \x01(\x05R\tpartition\"X\n" +
+	"\x0ePartitionBlock\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x1a\n" +
+	"\bchecksum\x18\x02 \x01(\rR\bchecksum\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset2\x82\x01\n" +
 	"\tWorkerApi\x128\n" +
-	"\vHealthCheck\x12\x13.HealthCheckRequest\x1a\x14.HealthCheckResponseB\x0eZ\f./;workerapib\x06proto3"
+	"\vHealthCheck\x12\x13.HealthCheckRequest\x1a\x14.HealthCheckResponse\x12;\n" +
+	"\x0eFetchPartition\x12\x16.FetchPartitionRequest\x1a\x0f.PartitionBlock0\x01B\x0eZ\f./;workerapib\x06proto3"
 
 var (
 	file_workerapi_proto_rawDescOnce sync.Once
@@ -133,16 +253,20 @@ func file_workerapi_proto_rawDescGZIP() []byte {
 	return file_workerapi_proto_rawDescData
 }
 
-var file_workerapi_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_workerapi_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_workerapi_proto_goTypes = []any{
-	(*HealthCheckRequest)(nil),  // 0: HealthCheckRequest
-	(*HealthCheckResponse)(nil), // 1: HealthCheckResponse
+	(*HealthCheckRequest)(nil),    // 0: HealthCheckRequest
+	(*HealthCheckResponse)(nil),   // 1: HealthCheckResponse
+	(*FetchPartitionRequest)(nil), // 2: FetchPartitionRequest
+	(*PartitionBlock)(nil),        // 3: PartitionBlock
 }
 var file_workerapi_proto_depIdxs = []int32{
 	0, // 0: WorkerApi.HealthCheck:input_type -> HealthCheckRequest
-	1, // 1: WorkerApi.HealthCheck:output_type -> HealthCheckResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
+	2, // 1: WorkerApi.FetchPartition:input_type -> FetchPartitionRequest
+	1, // 2: WorkerApi.HealthCheck:output_type -> HealthCheckResponse
+	3, // 3: WorkerApi.FetchPartition:output_type -> PartitionBlock
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -159,7 +283,7 @@ func file_workerapi_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_workerapi_proto_rawDesc), len(file_workerapi_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	WorkerApi_HealthCheck_FullMethodName = "/WorkerApi/HealthCheck"
+	WorkerApi_HealthCheck_FullMethodName    = "/WorkerApi/HealthCheck"
+	WorkerApi_FetchPartition_FullMethodName = "/WorkerApi/FetchPartition"
 )
 
 // WorkerApiClient is the client API for WorkerApi service.
@@ -27,6 +28,7 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type WorkerApiClient interface {
 	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
+	FetchPartition(ctx context.Context, in *FetchPartitionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PartitionBlock], error)
 }
 
 type workerApiClient struct {
@@ -47,11 +49,31 @@ func (c *workerApiClient) HealthCheck(ctx context.Context, in *HealthCheckReques
 	return out, nil
 }
 
+func (c *workerApiClient) FetchPartition(ctx context.Context, in *FetchPartitionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PartitionBlock], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WorkerApi_ServiceDesc.Streams[0], WorkerApi_FetchPartition_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FetchPartitionRequest, PartitionBlock]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerApi_FetchPartitionClient = grpc.ServerStreamingClient[PartitionBlock]
+
 // WorkerApiServer is the server API for WorkerApi service.
 // All implementations must embed UnimplementedWorkerApiServer
 // for forward compatibility.
 type WorkerApiServer interface {
 	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
+	FetchPartition(*FetchPartitionRequest, grpc.ServerStreamingServer[PartitionBlock]) error
 	mustEmbedUnimplementedWorkerApiServer()
 }
 
@@ -65,6 +87,9 @@ type UnimplementedWorkerApiServer struct{}
 func (UnimplementedWorkerApiServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
 }
+func (UnimplementedWorkerApiServer) FetchPartition(*FetchPartitionRequest, grpc.ServerStreamingServer[PartitionBlock]) error {
+	return status.Errorf(codes.Unimplemented, "method FetchPartition not implemented")
+}
 func (UnimplementedWorkerApiServer) mustEmbedUnimplementedWorkerApiServer() {}
 func (UnimplementedWorkerApiServer) testEmbeddedByValue()                   {}
 
@@ -104,6 +129,17 @@ func _WorkerApi_HealthCheck_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WorkerApi_FetchPartition_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FetchPartitionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WorkerApiServer).FetchPartition(m, &grpc.GenericServerStream[FetchPartitionRequest, PartitionBlock]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerApi_FetchPartitionServer = grpc.ServerStreamingServer[PartitionBlock]
+
 // WorkerApi_ServiceDesc is the grpc.ServiceDesc for WorkerApi service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -116,6 +152,12 @@ var WorkerApi_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _WorkerApi_HealthCheck_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FetchPartition",
+			Handler:       _WorkerApi_FetchPartition_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "workerapi.proto",
 }